@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -14,12 +15,20 @@ type File interface {
 	ContentType() string
 }
 
+// Object is a stored file that can also be read at random offsets.
+type Object interface {
+	File
+	io.ReaderAt
+	Size() int64
+}
+
 type Instance interface {
 	Store(ctx context.Context, file File) (string, error)
-	Retrieve(ctx context.Context, id string) (File, error)
+	Retrieve(ctx context.Context, id string) (Object, error)
 }
 
 type sfile struct {
+	blob        []byte
 	data        []byte
 	size        int64
 	checksum    string
@@ -35,6 +44,10 @@ func (f *sfile) Read(p []byte) (int, error) {
 	return n, nil
 }
 
+func (f *sfile) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(f.blob).ReadAt(p, off)
+}
+
 func (f *sfile) Close() error {
 	return nil
 }
@@ -61,13 +74,15 @@ func NewStorage() Instance {
 
 func (s *storage) Store(ctx context.Context, file File) (string, error) {
 	defer file.Close() // nolint: errcheck
-	bytes, err := io.ReadAll(file)
+	data, err := io.ReadAll(file)
 	if err != nil {
 		return "", err
 	}
 
 	f := sfile{
-		data:        bytes,
+		blob:        data,
+		data:        data,
+		size:        int64(len(data)),
 		checksum:    file.Checksum(),
 		contentType: file.ContentType(),
 	}
@@ -76,7 +91,7 @@ func (s *storage) Store(ctx context.Context, file File) (string, error) {
 	return file.Checksum(), nil
 }
 
-func (s *storage) Retrieve(ctx context.Context, id string) (File, error) {
+func (s *storage) Retrieve(ctx context.Context, id string) (Object, error) {
 	f, ok := s.inner.Load(id)
 	if !ok {
 		return nil, errors.New("file not found")
@@ -1,6 +1,7 @@
 package filesvc
 
 import (
+	"github.com/humbornjo/mizu"
 	"github.com/humbornjo/mizu/mizuconnect"
 	"github.com/humbornjo/mizu/mizudi"
 	"google.golang.org/grpc"
@@ -37,11 +38,16 @@ func Initialize(global *config.Config) {
 		panic("serve prefix not loaded")
 	}
 
+	svc := &Service{storage.NewStorage()}
 	scope := mizudi.MustRetrieve[*mizuconnect.Scope]()
 	scope.
 		UseGateway(
 			filev1.RegisterFileServiceHandlerFromEndpoint,
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 		).
-		Register(&Service{storage.NewStorage()}, filev1connect.NewFileServiceHandler)
+		Register(svc, filev1connect.NewFileServiceHandler)
+
+	// Plain HTTP downloads with Range, If-Range and ETag support
+	srv := mizudi.MustRetrieve[*mizu.Server]()
+	srv.Get("/raw/file/{id}", svc.ServeFile)
 }
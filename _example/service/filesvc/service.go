@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"net/http"
 
 	"connectrpc.com/connect"
 	"github.com/humbornjo/mizu"
@@ -58,7 +59,7 @@ func (s *Service) UploadFile(ctx context.Context, stream *connect.ClientStream[f
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
-	rxFile := mizu.NewFileReader(fpart, mizu.WithFileLimitBytes(64*1024*1024))
+	rxFile := mizu.NewFileReader(fpart, mizu.WithFileLimitBytes(64*1024*1024), mizu.WithFileContext(ctx))
 	id, err := s.storage.Store(ctx, rxFile)
 	if err != nil {
 		slog.ErrorContext(ctx, "failed store file", "err", err)
@@ -109,3 +110,23 @@ func (s *Service) DownloadFile(
 	)
 	return nil
 }
+
+// ServeFile serves stored files over plain HTTP with range and conditional
+// request support, keyed by the checksum ETag.
+func (s *Service) ServeFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	file, err := s.storage.Retrieve(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = mizu.ServeDownload(
+		w, r, file, file.Size(), file.Checksum(),
+		mizu.WithDownloadContentType(file.ContentType()),
+		mizu.WithDownloadFilename(id),
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to serve file", "id", id, "err", err)
+	}
+}
@@ -0,0 +1,317 @@
+package mizu
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrArchiveUnsupported is returned when the file is not a tar, tar.gz
+	// or zip archive, or contains an entry type other than a regular file
+	// or a directory.
+	ErrArchiveUnsupported = errors.New("unsupported archive")
+
+	// ErrArchiveLimit is returned when an archive exceeds the entry count,
+	// total size or compression ratio limit.
+	ErrArchiveLimit = errors.New("archive limit exceeded")
+
+	// ErrArchiveUnsafeEntry is returned for entries whose path escapes the
+	// extraction directory and for symbolic or hard links.
+	ErrArchiveUnsafeEntry = errors.New("unsafe archive entry")
+)
+
+// ArchiveFormat identifies the container format of an inspected archive.
+type ArchiveFormat string
+
+const (
+	ARCHIVE_FORMAT_TAR   ArchiveFormat = "tar"
+	ARCHIVE_FORMAT_TARGZ ArchiveFormat = "tar.gz"
+	ARCHIVE_FORMAT_ZIP   ArchiveFormat = "zip"
+)
+
+// ArchiveEntry describes a single entry of an inspected archive. Regular
+// files carry the SHA-256 checksum and sniffed MIME type of their content,
+// the same way FileReader reports them for a whole upload.
+type ArchiveEntry struct {
+	Name        string
+	Size        int64
+	Mode        fs.FileMode
+	ModTime     time.Time
+	IsDir       bool
+	Checksum    string
+	ContentType string
+}
+
+// ArchiveInspection is the result of InspectArchive.
+type ArchiveInspection struct {
+	Format     ArchiveFormat
+	Entries    []ArchiveEntry
+	TotalBytes int64
+}
+
+// ArchiveOption configures InspectArchive.
+type ArchiveOption func(*archiveConfig)
+
+type archiveConfig struct {
+	maxEntries int
+	maxBytes   int64
+	maxRatio   float64
+	spillDir   string
+}
+
+// WithArchiveMaxEntries sets the maximum number of entries, directories
+// included. The default is 10000.
+func WithArchiveMaxEntries(limit int) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.maxEntries = limit
+	}
+}
+
+// WithArchiveMaxBytes sets the maximum total uncompressed size of all
+// entries. The default is 1 GiB.
+func WithArchiveMaxBytes(limit int64) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.maxBytes = limit
+	}
+}
+
+// WithArchiveMaxRatio sets the maximum ratio of uncompressed to compressed
+// bytes, checked per zip entry and for the whole archive. The default is
+// 100.
+func WithArchiveMaxRatio(ratio float64) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.maxRatio = ratio
+	}
+}
+
+// WithArchiveSpillDir sets the directory zip uploads are spilled to, since
+// the zip central directory sits at the end of the file. The default is
+// os.TempDir.
+func WithArchiveSpillDir(dir string) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.spillDir = dir
+	}
+}
+
+// InspectArchive reads a tar, tar.gz or zip upload to the end and lists
+// its entries. Tar streams are inspected as they arrive, zip uploads are
+// spilled to a temporary file that is removed before InspectArchive
+// returns. Every entry is checked against the limits, and entries that
+// are links, absolute, or escape the archive root with `..` are rejected
+// with ErrArchiveUnsafeEntry.
+func InspectArchive(file *FileReader, opts ...ArchiveOption) (*ArchiveInspection, error) {
+	if file == nil {
+		return nil, errors.New("archive file is nil")
+	}
+	config := &archiveConfig{maxEntries: 10000, maxBytes: 1 << 30, maxRatio: 100}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.maxEntries <= 0 || config.maxBytes <= 0 || config.maxRatio < 1 {
+		return nil, errors.New("archive limits must be positive and the ratio at least 1")
+	}
+
+	inspector := &archiveInspector{config: config, file: file}
+	sniff := file.MimeSniffer()
+	switch {
+	case bytes.HasPrefix(sniff, []byte("PK\x03\x04")), bytes.HasPrefix(sniff, []byte("PK\x05\x06")):
+		return inspector.inspectZip()
+	case bytes.HasPrefix(sniff, []byte("\x1f\x8b")):
+		return inspector.inspectTarGz()
+	case len(sniff) >= 262 && string(sniff[257:262]) == "ustar":
+		return inspector.inspectTar(file, ARCHIVE_FORMAT_TAR)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrArchiveUnsupported, file.ContentType())
+}
+
+type archiveInspector struct {
+	config *archiveConfig
+	file   *FileReader
+	result ArchiveInspection
+}
+
+func (i *archiveInspector) inspectTarGz() (*ArchiveInspection, error) {
+	inner, err := gzip.NewReader(i.file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrArchiveUnsupported, err)
+	}
+	defer inner.Close() // nolint: errcheck
+	return i.inspectTar(inner, ARCHIVE_FORMAT_TARGZ)
+}
+
+func (i *archiveInspector) inspectTar(src io.Reader, format ArchiveFormat) (*ArchiveInspection, error) {
+	i.result.Format = format
+	reader := tar.NewReader(src)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeSymlink, tar.TypeLink:
+			return nil, fmt.Errorf("%w: %s is a link", ErrArchiveUnsafeEntry, header.Name)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return nil, fmt.Errorf("%w: %s has type %q", ErrArchiveUnsupported, header.Name, header.Typeflag)
+		}
+
+		entry := ArchiveEntry{
+			Name:    header.Name,
+			Mode:    header.FileInfo().Mode(),
+			ModTime: header.ModTime,
+			IsDir:   header.Typeflag == tar.TypeDir,
+		}
+		if err := i.visit(&entry, reader); err != nil {
+			return nil, err
+		}
+		if err := i.checkRatio(i.result.TotalBytes, i.file.ReadSize()); err != nil {
+			return nil, err
+		}
+	}
+	return &i.result, nil
+}
+
+func (i *archiveInspector) inspectZip() (*ArchiveInspection, error) {
+	i.result.Format = ARCHIVE_FORMAT_ZIP
+	spill, err := os.CreateTemp(i.config.spillDir, "mizu-archive-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("spill archive: %w", err)
+	}
+	defer os.Remove(spill.Name()) // nolint: errcheck
+	defer spill.Close()           // nolint: errcheck
+
+	size, err := io.Copy(spill, i.file)
+	if err != nil {
+		return nil, fmt.Errorf("spill archive: %w", err)
+	}
+	reader, err := zip.NewReader(spill, size)
+	if errors.Is(err, zip.ErrInsecurePath) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrArchiveUnsupported, err)
+	}
+	if len(reader.File) > i.config.maxEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, i.config.maxEntries)
+	}
+
+	// Reject on the sizes declared by the central directory before
+	// decompressing anything, the actual sizes are enforced while reading.
+	var declared uint64
+	for _, item := range reader.File {
+		declared += item.UncompressedSize64
+		if declared > uint64(i.config.maxBytes) {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrArchiveLimit, i.config.maxBytes)
+		}
+		if err := i.checkEntryRatio(item.Name, item.UncompressedSize64, item.CompressedSize64); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range reader.File {
+		mode := item.Mode()
+		if mode&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("%w: %s is a link", ErrArchiveUnsafeEntry, item.Name)
+		}
+		if !mode.IsRegular() && !mode.IsDir() {
+			return nil, fmt.Errorf("%w: %s has mode %s", ErrArchiveUnsupported, item.Name, mode)
+		}
+
+		entry := ArchiveEntry{Name: item.Name, Mode: mode, ModTime: item.Modified, IsDir: mode.IsDir()}
+		if err := i.visitZip(&entry, item); err != nil {
+			return nil, err
+		}
+	}
+	if err := i.checkRatio(i.result.TotalBytes, size); err != nil {
+		return nil, err
+	}
+	return &i.result, nil
+}
+
+func (i *archiveInspector) visitZip(entry *ArchiveEntry, item *zip.File) error {
+	if entry.IsDir {
+		return i.visit(entry, nil)
+	}
+	content, err := item.Open()
+	if err != nil {
+		return fmt.Errorf("open archive entry %s: %w", item.Name, err)
+	}
+	defer content.Close() // nolint: errcheck
+	return i.visit(entry, content)
+}
+
+func (i *archiveInspector) visit(entry *ArchiveEntry, content io.Reader) error {
+	if err := validateArchivePath(entry.Name); err != nil {
+		return err
+	}
+	if len(i.result.Entries) >= i.config.maxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, i.config.maxEntries)
+	}
+
+	if !entry.IsDir && content != nil {
+		remaining := i.config.maxBytes - i.result.TotalBytes
+		reader := NewFileReader(io.NopCloser(io.LimitReader(content, remaining+1)))
+		if _, err := io.Copy(io.Discard, reader); err != nil {
+			return fmt.Errorf("read archive entry %s: %w", entry.Name, err)
+		}
+		if reader.ReadSize() > remaining {
+			return fmt.Errorf("%w: more than %d bytes", ErrArchiveLimit, i.config.maxBytes)
+		}
+		entry.Size = reader.ReadSize()
+		entry.Checksum = reader.Checksum()
+		entry.ContentType = reader.ContentType()
+		i.result.TotalBytes += entry.Size
+	}
+	i.result.Entries = append(i.result.Entries, *entry)
+	return nil
+}
+
+func (i *archiveInspector) checkEntryRatio(name string, uncompressed, compressed uint64) error {
+	if uncompressed == 0 {
+		return nil
+	}
+	if compressed == 0 || float64(uncompressed)/float64(compressed) > i.config.maxRatio {
+		return fmt.Errorf("%w: %s compression ratio above %g", ErrArchiveLimit, name, i.config.maxRatio)
+	}
+	return nil
+}
+
+func (i *archiveInspector) checkRatio(uncompressed, compressed int64) error {
+	if uncompressed > 0 && float64(uncompressed) > float64(max(compressed, 1))*i.config.maxRatio {
+		return fmt.Errorf("%w: compression ratio above %g", ErrArchiveLimit, i.config.maxRatio)
+	}
+	return nil
+}
+
+// validateArchivePath rejects entry names that would not stay inside the
+// extraction directory on any platform.
+func validateArchivePath(name string) error {
+	if name == "" || strings.ContainsAny(name, "\\\x00") {
+		return fmt.Errorf("%w: %q", ErrArchiveUnsafeEntry, name)
+	}
+	if strings.HasPrefix(name, "/") || len(name) >= 2 && name[1] == ':' {
+		return fmt.Errorf("%w: %s is absolute", ErrArchiveUnsafeEntry, name)
+	}
+	for segment := range strings.SplitSeq(path.Clean(name), "/") {
+		if segment == ".." {
+			return fmt.Errorf("%w: %s escapes the archive root", ErrArchiveUnsafeEntry, name)
+		}
+	}
+	return nil
+}
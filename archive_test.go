@@ -0,0 +1,206 @@
+package mizu_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveItem struct {
+	name     string
+	data     []byte
+	dir      bool
+	linkname string
+}
+
+func newTarArchive(t *testing.T, items ...archiveItem) []byte {
+	t.Helper()
+
+	var content bytes.Buffer
+	writer := tar.NewWriter(&content)
+	for _, item := range items {
+		header := &tar.Header{Name: item.name, Mode: 0o644, Size: int64(len(item.data)), Typeflag: tar.TypeReg}
+		switch {
+		case item.dir:
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+		case item.linkname != "":
+			header.Typeflag, header.Linkname = tar.TypeSymlink, item.linkname
+		}
+		require.NoError(t, writer.WriteHeader(header))
+		_, err := writer.Write(item.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return content.Bytes()
+}
+
+func newZipArchive(t *testing.T, items ...archiveItem) []byte {
+	t.Helper()
+
+	var content bytes.Buffer
+	writer := zip.NewWriter(&content)
+	for _, item := range items {
+		header := &zip.FileHeader{Name: item.name, Method: zip.Deflate}
+		switch {
+		case item.dir:
+			header.SetMode(os.ModeDir | 0o755)
+		case item.linkname != "":
+			header.SetMode(os.ModeSymlink | 0o777)
+			item.data = []byte(item.linkname)
+		}
+		part, err := writer.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = part.Write(item.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return content.Bytes()
+}
+
+func gzipArchive(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var content bytes.Buffer
+	writer := gzip.NewWriter(&content)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return content.Bytes()
+}
+
+func inspectArchive(data []byte, opts ...mizu.ArchiveOption) (*mizu.ArchiveInspection, error) {
+	return mizu.InspectArchive(mizu.NewFileReader(io.NopCloser(bytes.NewReader(data))), opts...)
+}
+
+func TestMizu_InspectArchive(t *testing.T) {
+	items := []archiveItem{
+		{name: "docs/", dir: true},
+		{name: "docs/readme.txt", data: []byte("hello, archive")},
+		{name: "image.png", data: []byte("\x89PNG\r\n\x1a\n")},
+	}
+	textChecksum := sha256.Sum256([]byte("hello, archive"))
+
+	testcases := []struct {
+		name   string
+		data   []byte
+		format mizu.ArchiveFormat
+	}{
+		{name: "tar", data: newTarArchive(t, items...), format: mizu.ARCHIVE_FORMAT_TAR},
+		{name: "tar.gz", data: gzipArchive(t, newTarArchive(t, items...)), format: mizu.ARCHIVE_FORMAT_TARGZ},
+		{name: "zip", data: newZipArchive(t, items...), format: mizu.ARCHIVE_FORMAT_ZIP},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			spill := t.TempDir()
+			inspection, err := inspectArchive(tc.data, mizu.WithArchiveSpillDir(spill))
+			require.NoError(t, err)
+			assert.Equal(t, tc.format, inspection.Format)
+			assert.Equal(t, int64(22), inspection.TotalBytes)
+			require.Len(t, inspection.Entries, 3)
+
+			assert.True(t, inspection.Entries[0].IsDir)
+			assert.Empty(t, inspection.Entries[0].Checksum)
+			text := inspection.Entries[1]
+			assert.Equal(t, "docs/readme.txt", text.Name)
+			assert.Equal(t, int64(14), text.Size)
+			assert.Equal(t, hex.EncodeToString(textChecksum[:]), text.Checksum)
+			assert.Equal(t, "text/plain; charset=utf-8", text.ContentType)
+			assert.Equal(t, "image/png", inspection.Entries[2].ContentType)
+
+			spilled, err := os.ReadDir(spill)
+			require.NoError(t, err)
+			assert.Empty(t, spilled, "spilled zip files must be removed")
+		})
+	}
+}
+
+func TestMizu_InspectArchiveRejections(t *testing.T) {
+	zeros := archiveItem{name: "zeros.bin", data: make([]byte, 1<<20)}
+
+	testcases := []struct {
+		name     string
+		data     []byte
+		opts     []mizu.ArchiveOption
+		expected error
+	}{
+		{
+			name:     "tar traversal",
+			data:     newTarArchive(t, archiveItem{name: "../etc/passwd", data: []byte("x")}),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "zip traversal",
+			data:     newZipArchive(t, archiveItem{name: "a/../../evil", data: []byte("x")}),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "zip absolute",
+			data:     newZipArchive(t, archiveItem{name: "/etc/passwd", data: []byte("x")}),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "zip backslash",
+			data:     newZipArchive(t, archiveItem{name: `..\evil`, data: []byte("x")}),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "tar symlink",
+			data:     gzipArchive(t, newTarArchive(t, archiveItem{name: "link", linkname: "/etc/passwd"})),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "zip symlink",
+			data:     newZipArchive(t, archiveItem{name: "link", linkname: "/etc/passwd"}),
+			expected: mizu.ErrArchiveUnsafeEntry,
+		},
+		{
+			name:     "entry count",
+			data:     newTarArchive(t, archiveItem{name: "a", data: []byte("a")}, archiveItem{name: "b", data: []byte("b")}),
+			opts:     []mizu.ArchiveOption{mizu.WithArchiveMaxEntries(1)},
+			expected: mizu.ErrArchiveLimit,
+		},
+		{
+			name:     "total bytes",
+			data:     newTarArchive(t, archiveItem{name: "a", data: []byte("too large")}),
+			opts:     []mizu.ArchiveOption{mizu.WithArchiveMaxBytes(4)},
+			expected: mizu.ErrArchiveLimit,
+		},
+		{
+			name:     "zip ratio",
+			data:     newZipArchive(t, zeros),
+			expected: mizu.ErrArchiveLimit,
+		},
+		{
+			name:     "tar.gz ratio",
+			data:     gzipArchive(t, newTarArchive(t, zeros)),
+			expected: mizu.ErrArchiveLimit,
+		},
+		{
+			name:     "unsupported",
+			data:     []byte("plain text is not an archive"),
+			expected: mizu.ErrArchiveUnsupported,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := inspectArchive(tc.data, tc.opts...)
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+
+	inspection, err := inspectArchive(newZipArchive(t, zeros), mizu.WithArchiveMaxRatio(10000))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<20), inspection.TotalBytes)
+}
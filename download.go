@@ -0,0 +1,399 @@
+package mizu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// _DOWNLOAD_MAX_RANGES bounds the number of ranges served as
+// multipart/byteranges, larger range sets are answered with the whole
+// representation.
+const _DOWNLOAD_MAX_RANGES = 32
+
+// DownloadOption configures ServeDownload.
+type DownloadOption func(*downloadConfig)
+
+type downloadConfig struct {
+	filename    string
+	inline      bool
+	contentType string
+}
+
+// WithDownloadFilename sets the filename announced by Content-Disposition.
+// Non-ASCII filenames are sent with an ASCII fallback and an RFC 5987
+// `filename*` parameter.
+func WithDownloadFilename(filename string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.filename = filename
+	}
+}
+
+// WithDownloadInline marks the content to be displayed by the client
+// instead of saved as an attachment.
+func WithDownloadInline() DownloadOption {
+	return func(c *downloadConfig) {
+		c.inline = true
+	}
+}
+
+// WithDownloadContentType sets the Content-Type of the content. By
+// default it is sniffed from the first 512 bytes.
+func WithDownloadContentType(contentType string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.contentType = contentType
+	}
+}
+
+// ServeDownload serves size bytes of content. The checksum, typically
+// FileReader.Checksum, becomes the strong ETag of the response and drives
+// If-Match, If-None-Match and If-Range. GET requests with a Range header
+// are answered with a single range or with multipart/byteranges.
+//
+// Invalid arguments are returned before anything is written, later errors
+// come from reading content or writing the response.
+func ServeDownload(
+	w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, checksum string,
+	opts ...DownloadOption,
+) error {
+	if content == nil {
+		return errors.New("download content is nil")
+	}
+	if size < 0 {
+		return fmt.Errorf("download size must not be negative, got %d", size)
+	}
+	etag, err := formatStrongETag(checksum)
+	if err != nil {
+		return err
+	}
+	config := &downloadConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && !matchETag(match, etag, false) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return nil
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && matchETag(match, etag, true) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotModified)
+		} else {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		return nil
+	}
+
+	contentType := config.contentType
+	if contentType == "" {
+		sniff := make([]byte, min(size, 512))
+		n, err := content.ReadAt(sniff, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("sniff download content: %w", err)
+		}
+		contentType = http.DetectContentType(sniff[:n])
+	}
+	if config.filename != "" {
+		disposition := "attachment"
+		if config.inline {
+			disposition = "inline"
+		}
+		header.Set("Content-Disposition", formatContentDisposition(disposition, config.filename))
+	}
+
+	var ranges []downloadRange
+	if raw := r.Header.Get("Range"); raw != "" && r.Method == http.MethodGet && matchIfRange(r, etag) {
+		var satisfiable bool
+		ranges, satisfiable = parseDownloadRanges(raw, size)
+		if !satisfiable {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return nil
+		}
+		return copyDownloadRange(w, content, downloadRange{start: 0, length: size})
+	case 1:
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Range", ranges[0].contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ranges[0].length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		return copyDownloadRange(w, content, ranges[0])
+	}
+
+	// Size the multipart body with a dry run so the response keeps a
+	// Content-Length.
+	counter := &countingWriter{}
+	parts := multipart.NewWriter(counter)
+	for _, item := range ranges {
+		if _, err := parts.CreatePart(item.header(contentType, size)); err != nil {
+			return err
+		}
+		counter.n += item.length
+	}
+	if err := parts.Close(); err != nil {
+		return err
+	}
+
+	header.Set("Content-Type", "multipart/byteranges; boundary="+parts.Boundary())
+	header.Set("Content-Length", strconv.FormatInt(counter.n, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	body := multipart.NewWriter(w)
+	if err := body.SetBoundary(parts.Boundary()); err != nil {
+		return err
+	}
+	for _, item := range ranges {
+		part, err := body.CreatePart(item.header(contentType, size))
+		if err != nil {
+			return err
+		}
+		if err := copyDownloadRange(part, content, item); err != nil {
+			return err
+		}
+	}
+	return body.Close()
+}
+
+// ServeDownloadSeeker is ServeDownload for content that can only seek.
+// Content implementing io.ReaderAt is read directly, otherwise reads are
+// serialized over Seek and Read.
+func ServeDownloadSeeker(
+	w http.ResponseWriter, r *http.Request, content io.ReadSeeker, checksum string, opts ...DownloadOption,
+) error {
+	if content == nil {
+		return errors.New("download content is nil")
+	}
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("seek download content: %w", err)
+	}
+	if readerAt, ok := content.(io.ReaderAt); ok {
+		return ServeDownload(w, r, readerAt, size, checksum, opts...)
+	}
+	return ServeDownload(w, r, &seekReaderAt{inner: content}, size, checksum, opts...)
+}
+
+type downloadRange struct {
+	start  int64
+	length int64
+}
+
+func (r downloadRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+func (r downloadRange) header(contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {contentType},
+		"Content-Range": {r.contentRange(size)},
+	}
+}
+
+// parseDownloadRanges parses a Range header per RFC 9110. Ranges outside
+// of the content are dropped, and the result is unsatisfiable when none
+// remains. A malformed header, an unknown unit or a range set larger
+// than the content yields no ranges, so the whole content is served.
+func parseDownloadRanges(raw string, size int64) ([]downloadRange, bool) {
+	unit, specs, ok := strings.Cut(raw, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, true
+	}
+
+	var ranges []downloadRange
+	var total, specCount int64
+	for spec := range strings.SplitSeq(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, true
+		}
+		specCount++
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var item downloadRange
+		if first == "" {
+			suffix, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, true
+			}
+			if suffix == 0 || size == 0 {
+				continue
+			}
+			suffix = min(suffix, size)
+			item = downloadRange{start: size - suffix, length: suffix}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, true
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, true
+				}
+			}
+			if start >= size {
+				continue
+			}
+			end = min(end, size-1)
+			item = downloadRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, item)
+		total += item.length
+	}
+	if len(ranges) == 0 {
+		return nil, specCount == 0
+	}
+	if len(ranges) > _DOWNLOAD_MAX_RANGES || total > size {
+		return nil, true
+	}
+	return ranges, true
+}
+
+func copyDownloadRange(w io.Writer, content io.ReaderAt, item downloadRange) error {
+	_, err := io.Copy(w, io.NewSectionReader(content, item.start, item.length))
+	if err != nil {
+		return fmt.Errorf("write download content: %w", err)
+	}
+	return nil
+}
+
+func formatStrongETag(checksum string) (string, error) {
+	if checksum == "" {
+		return "", nil
+	}
+	for index := range len(checksum) {
+		if char := checksum[index]; char < 0x21 || char == '"' || char == 0x7f {
+			return "", fmt.Errorf("invalid download checksum %q", checksum)
+		}
+	}
+	return `"` + checksum + `"`, nil
+}
+
+// matchETag reports whether an If-Match or If-None-Match header matches
+// etag. If-None-Match compares weakly, If-Match strongly.
+func matchETag(header, etag string, weak bool) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if etag == "" {
+			continue
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIfRange reports whether the Range header may be honoured. An
+// If-Range date never matches since the content has no modification
+// time, and entity tags must match strongly.
+func matchIfRange(r *http.Request, etag string) bool {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+	return etag != "" && ifRange == etag
+}
+
+// formatContentDisposition builds a Content-Disposition header whose
+// filename parameter is a quoted ASCII fallback, accompanied by an RFC
+// 5987 filename* parameter when the fallback cannot carry the name exactly.
+func formatContentDisposition(disposition, filename string) string {
+	if index := strings.LastIndexAny(filename, `/\`); index >= 0 {
+		filename = filename[index+1:]
+	}
+
+	var fallback strings.Builder
+	plain := true
+	for _, char := range filename {
+		switch {
+		case char == '"' || char == '\\' || char < 0x20 || char == 0x7f:
+			plain = false
+			fallback.WriteByte('_')
+		case char >= utf8.RuneSelf:
+			plain = false
+			fallback.WriteByte('_')
+		default:
+			fallback.WriteRune(char)
+		}
+	}
+	value := fmt.Sprintf(`%s; filename="%s"`, disposition, fallback.String())
+	if plain {
+		return value
+	}
+
+	var encoded strings.Builder
+	for index := range len(filename) {
+		char := filename[index]
+		if 'A' <= char && char <= 'Z' || 'a' <= char && char <= 'z' || '0' <= char && char <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", char) >= 0 {
+			encoded.WriteByte(char)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", char)
+	}
+	return value + "; filename*=UTF-8''" + encoded.String()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type seekReaderAt struct {
+	mu    sync.Mutex
+	inner io.ReadSeeker
+}
+
+func (r *seekReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.inner.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.inner, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,216 @@
+package mizu_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const downloadContent = "hello, downloadable mizu"
+
+func downloadChecksum() string {
+	checksum := sha256.Sum256([]byte(downloadContent))
+	return hex.EncodeToString(checksum[:])
+}
+
+func serveDownload(
+	t *testing.T, method string, header map[string]string, opts ...mizu.DownloadOption,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(method, "/download", nil)
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	content := strings.NewReader(downloadContent)
+	require.NoError(t, mizu.ServeDownload(
+		recorder, request, content, int64(len(downloadContent)), downloadChecksum(), opts...,
+	))
+	return recorder
+}
+
+func TestMizu_ServeDownload(t *testing.T) {
+	etag := `"` + downloadChecksum() + `"`
+
+	testcases := []struct {
+		name    string
+		method  string
+		header  map[string]string
+		status  int
+		body    string
+		headers map[string]string
+	}{
+		{
+			name: "full", method: http.MethodGet, status: http.StatusOK, body: downloadContent,
+			headers: map[string]string{
+				"ETag": etag, "Accept-Ranges": "bytes", "Content-Length": "24",
+				"Content-Type": "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "head", method: http.MethodHead, status: http.StatusOK,
+			headers: map[string]string{"Content-Length": "24"},
+		},
+		{
+			name: "single range", method: http.MethodGet, status: http.StatusPartialContent,
+			header: map[string]string{"Range": "bytes=7-18"}, body: "downloadable",
+			headers: map[string]string{"Content-Range": "bytes 7-18/24", "Content-Length": "12"},
+		},
+		{
+			name: "suffix range", method: http.MethodGet, status: http.StatusPartialContent,
+			header: map[string]string{"Range": "bytes=-4"}, body: "mizu",
+			headers: map[string]string{"Content-Range": "bytes 20-23/24"},
+		},
+		{
+			name: "open range", method: http.MethodGet, status: http.StatusPartialContent,
+			header: map[string]string{"Range": "bytes=20-100"}, body: "mizu",
+			headers: map[string]string{"Content-Range": "bytes 20-23/24"},
+		},
+		{
+			name: "unsatisfiable range", method: http.MethodGet, status: http.StatusRequestedRangeNotSatisfiable,
+			header:  map[string]string{"Range": "bytes=24-"},
+			headers: map[string]string{"Content-Range": "bytes */24"},
+		},
+		{
+			name: "malformed range", method: http.MethodGet, status: http.StatusOK,
+			header: map[string]string{"Range": "bytes=x-1"}, body: downloadContent,
+		},
+		{
+			name: "range ignored on head", method: http.MethodHead, status: http.StatusOK,
+			header: map[string]string{"Range": "bytes=0-1"},
+		},
+		{
+			name: "if-range match", method: http.MethodGet, status: http.StatusPartialContent,
+			header: map[string]string{"Range": "bytes=0-4", "If-Range": etag}, body: "hello",
+		},
+		{
+			name: "if-range mismatch", method: http.MethodGet, status: http.StatusOK,
+			header: map[string]string{"Range": "bytes=0-4", "If-Range": `"stale"`}, body: downloadContent,
+		},
+		{
+			name: "if-range weak", method: http.MethodGet, status: http.StatusOK,
+			header: map[string]string{"Range": "bytes=0-4", "If-Range": "W/" + etag}, body: downloadContent,
+		},
+		{
+			name: "if-none-match", method: http.MethodGet, status: http.StatusNotModified,
+			header: map[string]string{"If-None-Match": `"other", W/` + etag}, headers: map[string]string{"ETag": etag},
+		},
+		{
+			name: "if-none-match any", method: http.MethodPost, status: http.StatusPreconditionFailed,
+			header: map[string]string{"If-None-Match": "*"},
+		},
+		{
+			name: "if-match", method: http.MethodGet, status: http.StatusOK,
+			header: map[string]string{"If-Match": etag}, body: downloadContent,
+		},
+		{
+			name: "if-match weak", method: http.MethodGet, status: http.StatusPreconditionFailed,
+			header: map[string]string{"If-Match": "W/" + etag},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := serveDownload(t, tc.method, tc.header)
+			assert.Equal(t, tc.status, recorder.Code)
+			assert.Equal(t, tc.body, recorder.Body.String())
+			for key, value := range tc.headers {
+				assert.Equal(t, value, recorder.Header().Get(key), key)
+			}
+		})
+	}
+}
+
+func TestMizu_ServeDownloadMultiRange(t *testing.T) {
+	recorder := serveDownload(t, http.MethodGet, map[string]string{"Range": "bytes=0-4, -4"},
+		mizu.WithDownloadContentType("text/plain"))
+	require.Equal(t, http.StatusPartialContent, recorder.Code)
+
+	mediaType, params, err := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+	assert.Equal(t, strconv.Itoa(recorder.Body.Len()), recorder.Header().Get("Content-Length"))
+
+	reader := multipart.NewReader(recorder.Body, params["boundary"])
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-4/24", "hello"},
+		{"bytes 20-23/24", "mizu"},
+	}
+	for _, item := range expected {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+		assert.Equal(t, item.contentRange, part.Header.Get("Content-Range"))
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, item.body, string(data))
+	}
+	_, err = reader.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	recorder = serveDownload(t, http.MethodGet, map[string]string{"Range": "bytes=0-20,1-22"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "overlapping ranges larger than the content are ignored")
+}
+
+func TestMizu_ServeDownloadDisposition(t *testing.T) {
+	testcases := []struct {
+		name        string
+		filename    string
+		opts        []mizu.DownloadOption
+		disposition string
+	}{
+		{name: "ascii", filename: "report.pdf", disposition: `attachment; filename="report.pdf"`},
+		{
+			name: "inline", filename: "photo.png", opts: []mizu.DownloadOption{mizu.WithDownloadInline()},
+			disposition: `inline; filename="photo.png"`,
+		},
+		{
+			name: "non-ascii", filename: "résumé 2024.pdf",
+			disposition: `attachment; filename="r_sum_ 2024.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%202024.pdf`,
+		},
+		{
+			name: "quote", filename: `say "hi".txt`,
+			disposition: `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`,
+		},
+		{
+			name: "unsafe", filename: "../evil\"\r\n.txt",
+			disposition: `attachment; filename="evil___.txt"; filename*=UTF-8''evil%22%0D%0A.txt`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]mizu.DownloadOption{mizu.WithDownloadFilename(tc.filename)}, tc.opts...)
+			recorder := serveDownload(t, http.MethodGet, nil, opts...)
+			assert.Equal(t, tc.disposition, recorder.Header().Get("Content-Disposition"))
+		})
+	}
+}
+
+func TestMizu_ServeDownloadSeeker(t *testing.T) {
+	content := struct{ io.ReadSeeker }{strings.NewReader(downloadContent)}
+	request := httptest.NewRequest(http.MethodGet, "/download", nil)
+	request.Header.Set("Range", "bytes=0-4,7-18")
+	recorder := httptest.NewRecorder()
+	require.NoError(t, mizu.ServeDownloadSeeker(recorder, request, content, downloadChecksum()))
+	assert.Equal(t, http.StatusPartialContent, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "hello")
+	assert.Contains(t, recorder.Body.String(), "downloadable")
+
+	err := mizu.ServeDownload(httptest.NewRecorder(), request, strings.NewReader(""), 0, `bad"etag`)
+	assert.ErrorContains(t, err, "invalid download checksum")
+	err = mizu.ServeDownload(httptest.NewRecorder(), request, nil, 0, "")
+	assert.ErrorContains(t, err, "download content is nil")
+}
@@ -5,8 +5,10 @@ import (
 	"encoding"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -18,8 +20,18 @@ var (
 	_TEXT_UNMARSHALER_TYPE = reflect.TypeFor[encoding.TextUnmarshaler]()
 )
 
+// _FIELD_MASK_WILDCARD is the path segment selecting every field of a
+// struct, every key of a map or every element of a slice.
+const _FIELD_MASK_WILDCARD = "*"
+
 // FieldMask is an immutable set of JSON field paths bound to T.
-// Construct one with Intersect.
+// Construct one with NewFieldMask, Intersect or ParseFieldMask.
+//
+// A path segment following a map selects a key, and a segment following a
+// slice or array selects an element by index. The wildcard `*` selects
+// every field, key or element, as in `labels.*` or `items.*.price`. Other
+// segments pass through slices and arrays, so `items.price` is the same
+// as `items.*.price`.
 type FieldMask[T any] struct {
 	typ   reflect.Type
 	paths []string
@@ -38,29 +50,68 @@ type fieldMaskField struct {
 	typ    reflect.Type
 }
 
+// FieldMaskError describes a path rejected while building a field mask.
+type FieldMaskError struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldMaskError) Error() string {
+	return fmt.Sprintf("field mask path %q: %s", e.Path, e.Reason)
+}
+
+// FieldMaskErrors collects every path rejected while building a field
+// mask.
+type FieldMaskErrors []FieldMaskError
+
+func (e FieldMaskErrors) Error() string {
+	messages := make([]string, len(e))
+	for index, err := range e {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 // Intersect returns a field mask containing the structurally valid overlap
 // between allowed and requested. Malformed, unknown, and disallowed paths are
 // omitted.
 func Intersect[T any](allowed, requested []string) *FieldMask[T] {
 	typ := reflect.TypeFor[T]()
-	mask := &FieldMask[T]{typ: typ, root: newFieldMaskNode()}
 	if !isFieldMaskStruct(typ) {
-		return mask
+		return newFieldMask[T](nil)
 	}
+	return newFieldMask[T](intersectFieldMaskPaths(
+		typ, validFieldMaskPaths(typ, allowed), validFieldMaskPaths(typ, requested),
+	))
+}
 
-	allowed = validFieldMaskPaths(typ, allowed)
-	requested = validFieldMaskPaths(typ, requested)
-	paths := make([]string, 0)
-	for _, left := range allowed {
-		for _, right := range requested {
-			switch {
-			case hasFieldMaskPrefix(left, right):
-				paths = append(paths, left)
-			case hasFieldMaskPrefix(right, left):
-				paths = append(paths, right)
+// NewFieldMask returns a field mask of paths, rejecting every path that
+// is malformed, unknown to T, or ambiguous between embedded fields. The
+// rejected paths are returned as FieldMaskErrors with their reasons.
+func NewFieldMask[T any](paths ...string) (*FieldMask[T], error) {
+	typ := reflect.TypeFor[T]()
+	var errs FieldMaskErrors
+	for _, path := range paths {
+		parts := strings.Split(path, ".")
+		switch {
+		case slices.Contains(parts, ""):
+			errs = append(errs, FieldMaskError{Path: path, Reason: "malformed path"})
+		case !isFieldMaskStruct(typ):
+			errs = append(errs, FieldMaskError{Path: path, Reason: "field mask type is not a JSON struct"})
+		default:
+			if reason := fieldMaskPathReason(typ, parts); reason != "" {
+				errs = append(errs, FieldMaskError{Path: path, Reason: reason})
 			}
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return newFieldMask[T](paths), nil
+}
+
+func newFieldMask[T any](paths []string) *FieldMask[T] {
+	mask := &FieldMask[T]{typ: reflect.TypeFor[T](), root: newFieldMaskNode()}
 	mask.paths = normalizeFieldMaskPaths(paths)
 	for _, path := range mask.paths {
 		mask.root.add(strings.Split(path, "."))
@@ -68,6 +119,71 @@ func Intersect[T any](allowed, requested []string) *FieldMask[T] {
 	return mask
 }
 
+// intersectFieldMaskPaths meets every allowed path with every requested
+// one. Both sides are made explicit first, so that `items.price` meets
+// `items.*.price` and `items.0.price`, and a meet equal to one side keeps
+// the spelling of that side.
+func intersectFieldMaskPaths(typ reflect.Type, allowed, requested []string) []string {
+	explicit := make([]string, len(requested))
+	for index, right := range requested {
+		explicit[index] = explicitFieldMaskPath(typ, right)
+	}
+	paths := make([]string, 0)
+	for _, left := range allowed {
+		explicitLeft := explicitFieldMaskPath(typ, left)
+		for index, right := range requested {
+			path, ok := meetFieldMaskPaths(explicitLeft, explicit[index])
+			switch {
+			case !ok:
+				continue
+			case path == explicit[index]:
+				path = right
+			case path == explicitLeft:
+				path = left
+			}
+			if validFieldMaskPath(typ, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// meetFieldMaskPaths returns the path selecting what both left and right
+// select, narrowing wildcards to the segment of the other side.
+func meetFieldMaskPaths(left, right string) (string, bool) {
+	leftParts, rightParts := strings.Split(left, "."), strings.Split(right, ".")
+	if len(leftParts) < len(rightParts) {
+		leftParts, rightParts = rightParts, leftParts
+	}
+	parts := slices.Clone(leftParts)
+	for index, part := range rightParts {
+		switch {
+		case part == parts[index], part == _FIELD_MASK_WILDCARD:
+		case parts[index] == _FIELD_MASK_WILDCARD:
+			parts[index] = part
+		default:
+			return "", false
+		}
+	}
+	return strings.Join(parts, "."), true
+}
+
+// matchFieldMaskPrefix reports whether path lies under pattern, whose
+// wildcard segments match any segment.
+func matchFieldMaskPrefix(path, pattern string) bool {
+	pathParts, patternParts := strings.Split(path, "."), strings.Split(pattern, ".")
+	if len(patternParts) > len(pathParts) {
+		return false
+	}
+	for index, part := range patternParts {
+		if part != _FIELD_MASK_WILDCARD && part != pathParts[index] {
+			return false
+		}
+	}
+	return true
+}
+
 // Paths returns a copy of the canonical paths in the field mask.
 func (m *FieldMask[T]) Paths() []string {
 	if m == nil {
@@ -148,6 +264,65 @@ func (n *fieldMaskNode) add(parts []string) {
 	current.children = nil
 }
 
+// child returns the node applied to the field or map key name, merged
+// with the wildcard node.
+func (n *fieldMaskNode) child(name string) *fieldMaskNode {
+	return mergeFieldMaskNodes(n.children[name], n.children[_FIELD_MASK_WILDCARD])
+}
+
+// passthrough returns the node applied to every element of a slice or
+// array by the segments that are not an index, or nil.
+func (n *fieldMaskNode) passthrough() *fieldMaskNode {
+	var through *fieldMaskNode
+	for name, child := range n.children {
+		if _, ok := parseFieldMaskIndex(name); ok {
+			continue
+		}
+		if through == nil {
+			through = newFieldMaskNode()
+		}
+		through.children[name] = child
+	}
+	return through
+}
+
+// element returns the node applied to element i of a slice or array.
+func (n *fieldMaskNode) element(i int, through *fieldMaskNode) *fieldMaskNode {
+	return mergeFieldMaskNodes(n.child(strconv.Itoa(i)), through)
+}
+
+func mergeFieldMaskNodes(left, right *fieldMaskNode) *fieldMaskNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.selected:
+		return left
+	case right.selected:
+		return right
+	}
+	merged := newFieldMaskNode()
+	maps.Copy(merged.children, left.children)
+	for name, child := range right.children {
+		merged.children[name] = mergeFieldMaskNodes(merged.children[name], child)
+	}
+	return merged
+}
+
+// parseFieldMaskIndex parses a slice element segment, the wildcard
+// parsing as -1.
+func parseFieldMaskIndex(part string) (int, bool) {
+	if part == _FIELD_MASK_WILDCARD {
+		return -1, true
+	}
+	if part == "" || strings.TrimLeft(part, "0123456789") != "" {
+		return 0, false
+	}
+	index, err := strconv.Atoi(part)
+	return index, err == nil
+}
+
 func validFieldMaskPaths(typ reflect.Type, paths []string) []string {
 	valid := make([]string, 0, len(paths))
 	for _, path := range paths {
@@ -166,36 +341,64 @@ func validFieldMaskPath(typ reflect.Type, path string) bool {
 	if slices.Contains(parts, "") {
 		return false
 	}
+	return validFieldMaskParts(typ, parts)
+}
+
+// validFieldMaskParts reports whether parts select at least one field of
+// typ.
+func validFieldMaskParts(typ reflect.Type, parts []string) bool {
+	return fieldMaskPathReason(typ, parts) == ""
+}
 
-	for index := 0; index < len(parts); {
+// fieldMaskPathReason returns why parts do not select any field of typ,
+// or an empty string. A wildcard over struct fields is valid if the rest
+// of the path is valid for any of them.
+func fieldMaskPathReason(typ reflect.Type, parts []string) string {
+	for len(parts) > 0 {
 		for typ.Kind() == reflect.Pointer {
 			typ = typ.Elem()
 		}
 		if isFieldMaskTerminal(typ) {
-			return false
+			return "unknown field"
 		}
 
 		switch typ.Kind() {
 		case reflect.Struct:
-			field, ok := fieldMaskFieldsByName(typ)[parts[index]]
-			if !ok {
-				return false
+			if parts[0] == _FIELD_MASK_WILDCARD {
+				if slices.ContainsFunc(fieldMaskFields(typ), func(field fieldMaskField) bool {
+					return validFieldMaskParts(field.typ, parts[1:])
+				}) {
+					return ""
+				}
+				return "unknown field"
+			}
+			fields, ambiguous := collectFieldMaskFields(typ)
+			index := slices.IndexFunc(fields, func(field fieldMaskField) bool { return field.name == parts[0] })
+			if index < 0 {
+				if slices.Contains(ambiguous, parts[0]) {
+					return "ambiguous field"
+				}
+				return "unknown field"
 			}
-			typ = field.typ
-			index++
+			typ, parts = fields[index].typ, parts[1:]
 		case reflect.Array, reflect.Slice:
+			if index, ok := parseFieldMaskIndex(parts[0]); ok {
+				if typ.Kind() == reflect.Array && index >= typ.Len() {
+					return "index out of range"
+				}
+				parts = parts[1:]
+			}
 			typ = typ.Elem()
 		case reflect.Map:
 			if typ.Key().Kind() != reflect.String {
-				return false
+				return "unknown field"
 			}
-			typ = typ.Elem()
-			index++
+			typ, parts = typ.Elem(), parts[1:]
 		default:
-			return false
+			return "unknown field"
 		}
 	}
-	return true
+	return ""
 }
 
 func normalizeFieldMaskPaths(paths []string) []string {
@@ -249,6 +452,14 @@ func fieldMaskFieldsByName(typ reflect.Type) map[string]fieldMaskField {
 }
 
 func fieldMaskFields(typ reflect.Type) []fieldMaskField {
+	fields, _ := collectFieldMaskFields(typ)
+	return fields
+}
+
+// collectFieldMaskFields returns the JSON-visible fields of typ, and the
+// names hidden because several embedded fields at the same depth carry
+// them.
+func collectFieldMaskFields(typ reflect.Type) ([]fieldMaskField, []string) {
 	current := []fieldMaskField{}
 	next := []fieldMaskField{{typ: typ}}
 	var count, nextCount map[reflect.Type]int
@@ -324,6 +535,7 @@ func fieldMaskFields(typ reflect.Type) []fieldMaskField {
 	})
 
 	visible := fields[:0]
+	var ambiguous []string
 	for i := 0; i < len(fields); {
 		end := i + 1
 		for end < len(fields) && fields[end].name == fields[i].name {
@@ -332,13 +544,15 @@ func fieldMaskFields(typ reflect.Type) []fieldMaskField {
 		group := fields[i:end]
 		if len(group) == 1 || len(group[0].index) != len(group[1].index) || group[0].tagged != group[1].tagged {
 			visible = append(visible, group[0])
+		} else {
+			ambiguous = append(ambiguous, group[0].name)
 		}
 		i = end
 	}
 	slices.SortFunc(visible, func(left, right fieldMaskField) int {
 		return slices.Compare(left.index, right.index)
 	})
-	return visible
+	return visible, ambiguous
 }
 
 func validFieldMaskTag(name string) bool {
@@ -392,7 +606,7 @@ func filterFieldMaskValue(value reflect.Value, node *fieldMaskNode) {
 	switch value.Kind() {
 	case reflect.Struct:
 		for _, field := range fieldMaskFields(value.Type()) {
-			child := node.children[field.name]
+			child := node.child(field.name)
 			fieldValue, ok := fieldMaskValueByIndex(value, field.index, false)
 			if !ok {
 				continue
@@ -406,12 +620,20 @@ func filterFieldMaskValue(value reflect.Value, node *fieldMaskNode) {
 			}
 		}
 	case reflect.Array, reflect.Slice:
+		through := node.passthrough()
 		for i := range value.Len() {
-			filterFieldMaskValue(value.Index(i), node)
+			child := node.element(i, through)
+			switch {
+			case child == nil:
+				value.Index(i).Set(reflect.Zero(value.Type().Elem()))
+			case child.selected:
+			default:
+				filterFieldMaskValue(value.Index(i), child)
+			}
 		}
 	case reflect.Map:
 		for _, key := range value.MapKeys() {
-			child := node.children[key.String()]
+			child := node.child(key.String())
 			if child == nil {
 				value.SetMapIndex(key, reflect.Value{})
 				continue
@@ -438,7 +660,7 @@ func pruneFieldMaskValue(value reflect.Value, node *fieldMaskNode) {
 	switch value.Kind() {
 	case reflect.Struct:
 		for _, field := range fieldMaskFields(value.Type()) {
-			child := node.children[field.name]
+			child := node.child(field.name)
 			if child == nil {
 				continue
 			}
@@ -453,17 +675,24 @@ func pruneFieldMaskValue(value reflect.Value, node *fieldMaskNode) {
 			pruneFieldMaskValue(fieldValue, child)
 		}
 	case reflect.Array, reflect.Slice:
+		through := node.passthrough()
 		for i := range value.Len() {
-			pruneFieldMaskValue(value.Index(i), node)
+			child := node.element(i, through)
+			switch {
+			case child == nil:
+			case child.selected:
+				value.Index(i).Set(reflect.Zero(value.Type().Elem()))
+			default:
+				pruneFieldMaskValue(value.Index(i), child)
+			}
 		}
 	case reflect.Map:
-		for name, child := range node.children {
-			key := reflect.New(value.Type().Key()).Elem()
-			key.SetString(name)
-			item := value.MapIndex(key)
-			if !item.IsValid() {
+		for _, key := range value.MapKeys() {
+			child := node.child(key.String())
+			if child == nil {
 				continue
 			}
+			item := value.MapIndex(key)
 			if child.selected {
 				value.SetMapIndex(key, reflect.Value{})
 				continue
@@ -495,7 +724,7 @@ func overwriteFieldMaskValue(source, target reflect.Value, node *fieldMaskNode)
 	switch source.Kind() {
 	case reflect.Struct:
 		for _, field := range fieldMaskFields(source.Type()) {
-			child := node.children[field.name]
+			child := node.child(field.name)
 			if child == nil {
 				continue
 			}
@@ -520,28 +749,46 @@ func overwriteFieldMaskValue(source, target reflect.Value, node *fieldMaskNode)
 			}
 			overwriteFieldMaskValue(sourceField, targetField, child)
 		}
-	case reflect.Array:
-		for i := range source.Len() {
-			overwriteFieldMaskValue(source.Index(i), target.Index(i), node)
-		}
-	case reflect.Slice:
-		if source.IsNil() {
-			target.Set(reflect.Zero(target.Type()))
-			return
-		}
-		length := source.Len()
-		if target.Cap() < length {
-			resized := reflect.MakeSlice(target.Type(), length, length)
-			reflect.Copy(resized, target)
-			target.Set(resized)
-		} else {
-			target.SetLen(length)
+	case reflect.Array, reflect.Slice:
+		// Paths through every element resize the destination to the
+		// source, paths selecting single indexes leave its length alone.
+		through := node.passthrough()
+		if source.Kind() == reflect.Slice && (through != nil || node.children[_FIELD_MASK_WILDCARD] != nil) {
+			if source.IsNil() {
+				target.Set(reflect.Zero(target.Type()))
+				return
+			}
+			length := source.Len()
+			if target.Cap() < length {
+				resized := reflect.MakeSlice(target.Type(), length, length)
+				reflect.Copy(resized, target)
+				target.Set(resized)
+			} else {
+				target.SetLen(length)
+			}
 		}
-		for i := range length {
-			overwriteFieldMaskValue(source.Index(i), target.Index(i), node)
+		for i := range min(source.Len(), target.Len()) {
+			child := node.element(i, through)
+			switch {
+			case child == nil:
+			case child.selected:
+				target.Index(i).Set(source.Index(i))
+			default:
+				overwriteFieldMaskValue(source.Index(i), target.Index(i), child)
+			}
 		}
 	case reflect.Map:
-		for name, child := range node.children {
+		names := slices.Collect(maps.Keys(node.children))
+		if node.children[_FIELD_MASK_WILDCARD] != nil {
+			names = slices.DeleteFunc(names, func(name string) bool { return name == _FIELD_MASK_WILDCARD })
+			for _, key := range slices.Concat(source.MapKeys(), target.MapKeys()) {
+				if name := key.String(); !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		for _, name := range names {
+			child := node.child(name)
 			key := reflect.New(source.Type().Key()).Elem()
 			key.SetString(name)
 			sourceItem := source.MapIndex(key)
@@ -0,0 +1,257 @@
+package mizu
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// DiffOption configures Diff.
+type DiffOption func(*diffConfig)
+
+type diffConfig struct {
+	deep bool
+}
+
+// WithDiffDeep reports changed fields of nested structs by their own path,
+// such as `address.city`, instead of the path of the outermost field.
+func WithDiffDeep() DiffOption {
+	return func(c *diffConfig) {
+		c.deep = true
+	}
+}
+
+// FieldMaskChange is a single changed path with its values before and
+// after the change.
+type FieldMaskChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Diff returns the mask of JSON-visible fields that differ between before
+// and after, following the same visibility rules as FieldMask: embedded
+// fields are promoted and fields tagged `json:"-"` are ignored. A nil value
+// compares as the zero value of T.
+//
+// Values implementing json.Marshaler or encoding.TextMarshaler are compared
+// as a whole, by their encoding. Other nested structs are compared field by field and, with
+// WithDiffDeep, reported by their nested paths.
+func Diff[T any](before, after *T, opts ...DiffOption) *FieldMask[T] {
+	config := &diffConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	typ := reflect.TypeFor[T]()
+	if !isFieldMaskStruct(typ) {
+		return newFieldMask[T](nil)
+	}
+
+	paths := make([]string, 0)
+	diffFieldMaskStruct(diffFieldMaskRoot(before), diffFieldMaskRoot(after), nil, config.deep, &paths)
+	return newFieldMask[T](paths)
+}
+
+// Changes renders the paths of the mask as before/after pairs, in the
+// order of Paths. Paths crossing slices yield slices of the projected
+// values, and missing values are nil.
+func (m *FieldMask[T]) Changes(before, after *T) []FieldMaskChange {
+	if m == nil {
+		return nil
+	}
+	changes := make([]FieldMaskChange, 0, len(m.paths))
+	left, right := diffFieldMaskRoot(before), diffFieldMaskRoot(after)
+	for _, path := range m.paths {
+		parts := strings.Split(path, ".")
+		changes = append(changes, FieldMaskChange{
+			Path:   path,
+			Before: fieldMaskPathValue(left, parts),
+			After:  fieldMaskPathValue(right, parts),
+		})
+	}
+	return changes
+}
+
+func diffFieldMaskRoot[T any](value *T) reflect.Value {
+	if value == nil {
+		return reflect.Zero(reflect.TypeFor[T]())
+	}
+	return reflect.ValueOf(value).Elem()
+}
+
+func diffFieldMaskStruct(before, after reflect.Value, prefix []string, deep bool, paths *[]string) {
+	for _, field := range fieldMaskFields(before.Type()) {
+		left, leftExists := fieldMaskValueByIndex(before, field.index, false)
+		right, rightExists := fieldMaskValueByIndex(after, field.index, false)
+		path := append(prefix[:len(prefix):len(prefix)], field.name)
+		switch {
+		case !leftExists && !rightExists:
+			continue
+		case leftExists != rightExists:
+			*paths = append(*paths, strings.Join(path, "."))
+			continue
+		}
+
+		if deep {
+			left, right = indirectFieldMaskPair(left, right)
+			if left.Kind() == reflect.Struct && right.Kind() == reflect.Struct && !isFieldMaskTerminal(left.Type()) {
+				diffFieldMaskStruct(left, right, path, deep, paths)
+				continue
+			}
+		}
+		if !equalFieldMaskValue(left, right) {
+			*paths = append(*paths, strings.Join(path, "."))
+		}
+	}
+}
+
+// indirectFieldMaskPair dereferences two pointers of the same type as long
+// as both are non-nil.
+func indirectFieldMaskPair(left, right reflect.Value) (reflect.Value, reflect.Value) {
+	for left.Kind() == reflect.Pointer && !left.IsNil() && !right.IsNil() {
+		left, right = left.Elem(), right.Elem()
+	}
+	return left, right
+}
+
+// equalFieldMaskValue compares the JSON-visible content of two values of
+// the same type. JSON terminal values compare by their encoding, so that a
+// time.Time with a monotonic clock reading equals its JSON round trip.
+func equalFieldMaskValue(left, right reflect.Value) bool {
+	if isFieldMaskTerminal(left.Type()) {
+		marshal := func(value reflect.Value) ([]byte, error) {
+			if value.CanAddr() {
+				return json.Marshal(value.Addr().Interface())
+			}
+			return json.Marshal(value.Interface())
+		}
+		leftJson, leftErr := marshal(left)
+		rightJson, rightErr := marshal(right)
+		if leftErr != nil || rightErr != nil {
+			return reflect.DeepEqual(left.Interface(), right.Interface())
+		}
+		return bytes.Equal(leftJson, rightJson)
+	}
+
+	switch left.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if left.IsNil() || right.IsNil() {
+			return left.IsNil() == right.IsNil()
+		}
+		if left.Kind() == reflect.Interface && left.Elem().Type() != right.Elem().Type() {
+			return false
+		}
+		return equalFieldMaskValue(left.Elem(), right.Elem())
+	case reflect.Struct:
+		for _, field := range fieldMaskFields(left.Type()) {
+			leftField, leftExists := fieldMaskValueByIndex(left, field.index, false)
+			rightField, rightExists := fieldMaskValueByIndex(right, field.index, false)
+			if leftExists != rightExists || leftExists && !equalFieldMaskValue(leftField, rightField) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if left.IsNil() != right.IsNil() {
+			return false
+		}
+		fallthrough
+	case reflect.Array:
+		if left.Len() != right.Len() {
+			return false
+		}
+		for i := range left.Len() {
+			if !equalFieldMaskValue(left.Index(i), right.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if left.IsNil() != right.IsNil() || left.Len() != right.Len() {
+			return false
+		}
+		for _, key := range left.MapKeys() {
+			item := right.MapIndex(key)
+			if !item.IsValid() || !equalFieldMaskValue(left.MapIndex(key), item) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(left.Interface(), right.Interface())
+}
+
+// fieldMaskPathValue returns the value at a field mask path, or nil when
+// the path is not present. Wildcards over struct fields and map keys yield
+// maps of the projected values, and slices are mapped over unless an index
+// selects a single element.
+func fieldMaskPathValue(value reflect.Value, parts []string) any {
+	for index := 0; index < len(parts); {
+		for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Struct:
+			if parts[index] == _FIELD_MASK_WILDCARD {
+				projected := make(map[string]any)
+				for _, field := range fieldMaskFields(value.Type()) {
+					if item, ok := fieldMaskValueByIndex(value, field.index, false); ok {
+						projected[field.name] = fieldMaskPathValue(item, parts[index+1:])
+					}
+				}
+				return projected
+			}
+			field, ok := fieldMaskFieldsByName(value.Type())[parts[index]]
+			if !ok {
+				return nil
+			}
+			if value, ok = fieldMaskValueByIndex(value, field.index, false); !ok {
+				return nil
+			}
+			index++
+		case reflect.Map:
+			if value.Type().Key().Kind() != reflect.String {
+				return nil
+			}
+			if parts[index] == _FIELD_MASK_WILDCARD {
+				projected := make(map[string]any, value.Len())
+				for _, key := range value.MapKeys() {
+					projected[key.String()] = fieldMaskPathValue(value.MapIndex(key), parts[index+1:])
+				}
+				return projected
+			}
+			key := reflect.New(value.Type().Key()).Elem()
+			key.SetString(parts[index])
+			if value = value.MapIndex(key); !value.IsValid() {
+				return nil
+			}
+			index++
+		case reflect.Array, reflect.Slice:
+			rest := parts[index:]
+			if element, ok := parseFieldMaskIndex(parts[index]); ok {
+				rest = parts[index+1:]
+				if element >= 0 {
+					if element >= value.Len() {
+						return nil
+					}
+					value = value.Index(element)
+					index++
+					continue
+				}
+			}
+			projected := make([]any, value.Len())
+			for i := range value.Len() {
+				projected[i] = fieldMaskPathValue(value.Index(i), rest)
+			}
+			return projected
+		default:
+			return nil
+		}
+	}
+	return value.Interface()
+}
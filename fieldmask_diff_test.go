@@ -0,0 +1,97 @@
+package mizu_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMaskDiffDocument struct {
+	FieldMaskEmbedded
+	*FieldMaskOptionalEmbedded
+	DisplayName string            `json:"displayName"`
+	Address     *fieldMaskAddress `json:"address"`
+	Home        fieldMaskAddress  `json:"home"`
+	Items       []fieldMaskItem   `json:"items"`
+	Custom      fieldMaskCustom   `json:"custom"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	Secret      string            `json:"-"`
+}
+
+func newFieldMaskDiffDocument() fieldMaskDiffDocument {
+	return fieldMaskDiffDocument{
+		FieldMaskEmbedded: FieldMaskEmbedded{Embedded: "embedded"},
+		DisplayName:       "name",
+		Address:           &fieldMaskAddress{City: "Shanghai", Zip: "200000"},
+		Home:              fieldMaskAddress{City: "Hangzhou", Zip: "310000"},
+		Items:             []fieldMaskItem{{Label: "first", Count: 1}},
+		Custom:            fieldMaskCustom{Nested: "a"},
+		UpdatedAt:         time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Secret:            "secret",
+	}
+}
+
+func TestMizu_Diff(t *testing.T) {
+	before := newFieldMaskDiffDocument()
+	after := newFieldMaskDiffDocument()
+	after.Embedded = "changed"
+	after.Address.City = "Beijing"
+	after.Home.Zip = "310001"
+	after.Items[0].Count = 2
+	after.Custom.Nested = "b"
+	after.UpdatedAt = after.UpdatedAt.Add(time.Hour)
+	after.Secret = "rotated"
+
+	assert.Equal(t, []string{"address", "embedded", "home", "items", "updatedAt"},
+		mizu.Diff(&before, &after).Paths(), "custom encodes the same either way")
+	assert.Equal(t, []string{"address.city", "embedded", "home.zip", "items", "updatedAt"},
+		mizu.Diff(&before, &after, mizu.WithDiffDeep()).Paths())
+
+	same := newFieldMaskDiffDocument()
+	same.Address = &fieldMaskAddress{City: "Shanghai", Zip: "200000"}
+	same.Secret = "hidden"
+	assert.Empty(t, mizu.Diff(&before, &same, mizu.WithDiffDeep()).Paths(), "pointers compare by content")
+
+	optional := newFieldMaskDiffDocument()
+	optional.FieldMaskOptionalEmbedded = &FieldMaskOptionalEmbedded{}
+	optional.Address = nil
+	assert.Equal(t, []string{"address", "optional", "sibling"},
+		mizu.Diff(&before, &optional, mizu.WithDiffDeep()).Paths())
+
+	assert.Equal(t, []string{"address", "displayName", "embedded", "home", "items", "updatedAt"},
+		mizu.Diff(nil, &before).Paths())
+
+	now := newFieldMaskDiffDocument()
+	now.UpdatedAt = time.Now()
+	encoded, err := json.Marshal(now.UpdatedAt)
+	require.NoError(t, err)
+	decoded := newFieldMaskDiffDocument()
+	require.NoError(t, json.Unmarshal(encoded, &decoded.UpdatedAt))
+	assert.Empty(t, mizu.Diff(&now, &decoded).Paths(), "monotonic clock readings are not JSON-visible")
+}
+
+func TestMizu_FieldMaskChanges(t *testing.T) {
+	before := newFieldMaskDiffDocument()
+	after := newFieldMaskDiffDocument()
+	after.DisplayName = "renamed"
+	after.Address = nil
+	after.Home.City = "Suzhou"
+	after.Items = append(after.Items, fieldMaskItem{Label: "second"})
+
+	diff := mizu.Diff(&before, &after, mizu.WithDiffDeep())
+	assert.Equal(t, []mizu.FieldMaskChange{
+		{Path: "address", Before: &fieldMaskAddress{City: "Shanghai", Zip: "200000"}, After: (*fieldMaskAddress)(nil)},
+		{Path: "displayName", Before: "name", After: "renamed"},
+		{Path: "home.city", Before: "Hangzhou", After: "Suzhou"},
+		{Path: "items", Before: before.Items, After: after.Items},
+	}, diff.Changes(&before, &after))
+
+	labels := mizu.Intersect[fieldMaskDiffDocument]([]string{"items.label"}, []string{"items"})
+	assert.Equal(t, []mizu.FieldMaskChange{
+		{Path: "items.label", Before: []any{"first"}, After: []any{"first", "second"}},
+	}, labels.Changes(&before, &after))
+}
@@ -0,0 +1,284 @@
+package mizu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// HEADER_FIELD_MASK is set on filtered responses and lists the canonical
+// paths of the applied field mask, comma separated.
+const HEADER_FIELD_MASK = "X-Field-Mask"
+
+// FieldMaskOption configures response filtering by field mask.
+type FieldMaskOption func(*fieldMaskConfig)
+
+type fieldMaskConfig struct {
+	strict bool
+}
+
+// WithFieldMaskStrict rejects requests selecting unknown, malformed or
+// disallowed paths instead of dropping those paths.
+func WithFieldMaskStrict() FieldMaskOption {
+	return func(c *fieldMaskConfig) {
+		c.strict = true
+	}
+}
+
+// FieldMaskFromContext returns the field mask stored by
+// NewFieldMaskMiddleware, so handlers can skip loading fields that will be
+// filtered anyway.
+func FieldMaskFromContext[T any](ctx context.Context) (*FieldMask[T], bool) {
+	mask, ok := ctx.Value(_CTXKEY_FIELD_MASK).(*FieldMask[T])
+	return mask, ok
+}
+
+// FilterFieldMaskResponse parses the mask selected by r with
+// ParseFieldMaskRequest, filters value in place and sets
+// HEADER_FIELD_MASK on w. It reports whether value was filtered, and
+// returns the rejected paths as FieldMaskErrors in strict mode, in which
+// case value is left untouched. Call it before the response header is
+// written.
+func FilterFieldMaskResponse[T any](
+	w http.ResponseWriter, r *http.Request, allowed []string, value *T, opts ...FieldMaskOption,
+) (bool, error) {
+	config := newFieldMaskConfig(opts)
+	mask, errs := ParseFieldMaskRequest[T](r, allowed)
+	if mask == nil {
+		return false, nil
+	}
+	if config.strict && len(errs) > 0 {
+		return false, errs
+	}
+	if err := mask.Filter(value); err != nil {
+		return false, err
+	}
+	w.Header().Set(HEADER_FIELD_MASK, strings.Join(mask.Paths(), ","))
+	return true, nil
+}
+
+// WriteFieldMaskResponse filters value by the mask NewFieldMaskMiddleware
+// stored in the context of r, if any, and encodes it as the JSON response
+// with status. The middleware passes such responses through, so value is
+// encoded once, with its own MarshalJSON.
+func WriteFieldMaskResponse[T any](w http.ResponseWriter, r *http.Request, status int, value *T) error {
+	if mask, ok := FieldMaskFromContext[T](r.Context()); ok {
+		if err := mask.Filter(value); err != nil {
+			return err
+		}
+		w.Header().Set(HEADER_FIELD_MASK, strings.Join(mask.Paths(), ","))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(value)
+}
+
+// NewFieldMaskMiddleware parses the mask the request selects through
+// `fields` or `$select`, intersected with allowed, and hands it to the
+// handler through FieldMaskFromContext. Handlers should filter the typed
+// value before encoding it, with WriteFieldMaskResponse or
+// FilterFieldMaskResponse.
+//
+// As a fallback for handlers unaware of the mask, successful JSON
+// responses of type T, or arrays of T, not filtered yet are buffered and
+// their JSON document is filtered by the mask paths, keeping the encoding
+// of every selected value. Documents with top-level keys T does not
+// declare, such as envelopes, and bodies that are not JSON are written
+// unchanged and logged. Other responses, such as errors and non-JSON
+// content types, and requests without a selection, pass through
+// untouched.
+//
+// In strict mode, requests with rejected paths are answered with 400 and
+// the FieldMaskErrors as a JSON array.
+func NewFieldMaskMiddleware[T any](allowed []string, opts ...FieldMaskOption) func(http.Handler) http.Handler {
+	config := newFieldMaskConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mask, errs := ParseFieldMaskRequest[T](r, allowed)
+			if mask == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if config.strict && len(errs) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(errs)
+				return
+			}
+
+			writer := &fieldMaskWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), _CTXKEY_FIELD_MASK, mask)))
+			if writer.buffered {
+				writeFieldMaskResponse(writer, r, mask)
+			}
+		})
+	}
+}
+
+func newFieldMaskConfig(opts []FieldMaskOption) *fieldMaskConfig {
+	config := &fieldMaskConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// writeFieldMaskResponse filters the buffered JSON document of a T, or of
+// an array of T, by mask, so values keep their own encoding. Bodies that
+// are not such documents, like envelopes with keys T does not declare,
+// are written unchanged without HEADER_FIELD_MASK, and logged.
+func writeFieldMaskResponse[T any](writer *fieldMaskWriter, r *http.Request, mask *FieldMask[T]) {
+	body := writer.body.Bytes()
+	var document any
+	err := decodeFieldMaskPatch(body, &document)
+	if err == nil {
+		items := []any{document}
+		if array, ok := document.([]any); ok && !isFieldMaskList(mask.typ) {
+			items = array
+		}
+		for _, item := range items {
+			if err = checkFieldMaskDocument(mask.typ, item); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			for _, item := range items {
+				filterFieldMaskDocument(item, mask.root)
+			}
+		}
+	}
+
+	header := writer.ResponseWriter.Header()
+	if err == nil {
+		var encoded bytes.Buffer
+		if err = json.NewEncoder(&encoded).Encode(document); err == nil {
+			body = encoded.Bytes()
+			header.Set(HEADER_FIELD_MASK, strings.Join(mask.Paths(), ","))
+			header.Del("Content-Length")
+		}
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "field mask response is not filtered", "path", r.URL.Path, "error", err)
+	}
+	writer.ResponseWriter.WriteHeader(writer.status)
+	_, _ = writer.ResponseWriter.Write(body)
+}
+
+func isFieldMaskList(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return !isFieldMaskTerminal(typ) && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array)
+}
+
+// checkFieldMaskDocument reports whether document can be the JSON
+// encoding of typ, looking at the keys of its top level object only.
+func checkFieldMaskDocument(typ reflect.Type, document any) error {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if !isFieldMaskStruct(typ) {
+		return nil
+	}
+	object, ok := document.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected a JSON object for %s", typ)
+	}
+	fields := fieldMaskFieldsByName(typ)
+	for name := range object {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("unknown field %q for %s", name, typ)
+		}
+	}
+	return nil
+}
+
+// filterFieldMaskDocument is filterFieldMaskValue for a decoded JSON
+// document. Unselected object keys are removed and unselected array
+// elements become null.
+func filterFieldMaskDocument(document any, node *fieldMaskNode) any {
+	switch value := document.(type) {
+	case map[string]any:
+		for name, item := range value {
+			child := node.child(name)
+			switch {
+			case child == nil:
+				delete(value, name)
+			case child.selected:
+			default:
+				value[name] = filterFieldMaskDocument(item, child)
+			}
+		}
+	case []any:
+		through := node.passthrough()
+		for index, item := range value {
+			child := node.element(index, through)
+			switch {
+			case child == nil:
+				value[index] = nil
+			case child.selected:
+			default:
+				value[index] = filterFieldMaskDocument(item, child)
+			}
+		}
+	}
+	return document
+}
+
+// fieldMaskWriter buffers successful JSON responses not filtered by the
+// handler so they can be filtered, and passes every other response
+// through.
+type fieldMaskWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffered    bool
+	body        bytes.Buffer
+}
+
+func (w *fieldMaskWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.wroteHeader, w.status = true, status
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.buffered = status >= 200 && status < 300 && w.Header().Get(HEADER_FIELD_MASK) == "" &&
+		(mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+	if !w.buffered {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *fieldMaskWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffered {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *fieldMaskWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *fieldMaskWriter) Flush() {
+	if w.buffered {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
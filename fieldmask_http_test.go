@@ -0,0 +1,200 @@
+package mizu_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMaskResponse struct {
+	DisplayName string            `json:"displayName"`
+	Age         int               `json:"age"`
+	Address     *fieldMaskAddress `json:"address,omitempty"`
+	Items       []fieldMaskItem   `json:"items,omitempty"`
+}
+
+func TestMizu_FieldMaskMiddleware(t *testing.T) {
+	allowed := []string{"displayName", "address.city", "items"}
+	profile := fieldMaskResponse{
+		DisplayName: "name",
+		Age:         42,
+		Address:     &fieldMaskAddress{City: "Shanghai", Zip: "200000"},
+		Items:       []fieldMaskItem{{Label: "first", Count: 1}, {Label: "second", Count: 2}},
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		opts     []mizu.FieldMaskOption
+		handler  http.HandlerFunc
+		status   int
+		header   string
+		expected string
+	}{
+		{
+			name:   "object",
+			target: "/?fields=displayName,address(city,zip)",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				mask, ok := mizu.FieldMaskFromContext[fieldMaskResponse](r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, []string{"address.city", "displayName"}, mask.Paths())
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "1024")
+				_ = json.NewEncoder(w).Encode(profile)
+			},
+			status:   http.StatusOK,
+			header:   "address.city,displayName",
+			expected: `{"displayName":"name","address":{"city":"Shanghai"}}`,
+		},
+		{
+			name:   "array",
+			target: "/?$select=items/label",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode([]fieldMaskResponse{profile})
+			},
+			status:   http.StatusCreated,
+			header:   "items.label",
+			expected: `[{"items":[{"label":"first"},{"label":"second"}]}]`,
+		},
+		{
+			name:   "value encoding",
+			target: "/?fields=displayName,address(city)",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"displayName":{"en":"name"},"age":42,"address":{"city":"Shanghai","zip":"200000"}}`))
+			},
+			status:   http.StatusOK,
+			header:   "address.city,displayName",
+			expected: `{"displayName":{"en":"name"},"address":{"city":"Shanghai"}}`,
+		},
+		{
+			name:   "envelope",
+			target: "/?fields=displayName",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"data":{"displayName":"name","age":42},"next":"token"}`))
+			},
+			status:   http.StatusOK,
+			expected: `{"data":{"displayName":"name","age":42},"next":"token"}`,
+		},
+		{
+			name:   "filtered by handler",
+			target: "/?fields=displayName",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				value := profile
+				require.NoError(t, mizu.WriteFieldMaskResponse(w, r, http.StatusOK, &value))
+			},
+			status:   http.StatusOK,
+			header:   "displayName",
+			expected: `{"displayName":"name","age":0}`,
+		},
+		{
+			name:   "non json",
+			target: "/?fields=displayName",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte(`{"age":1}`))
+			},
+			status:   http.StatusOK,
+			expected: `{"age":1}`,
+		},
+		{
+			name:   "no selection",
+			target: "/",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, ok := mizu.FieldMaskFromContext[fieldMaskResponse](r.Context())
+				assert.False(t, ok)
+				_, _ = w.Write([]byte(`{"displayName":"name"}`))
+			},
+			status:   http.StatusOK,
+			expected: `{"displayName":"name"}`,
+		},
+		{
+			name:   "error response",
+			target: "/?fields=displayName",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"age":1}`))
+			},
+			status:   http.StatusNotFound,
+			expected: `{"age":1}`,
+		},
+		{
+			name:   "strict",
+			target: "/?fields=displayName,secret",
+			opts:   []mizu.FieldMaskOption{mizu.WithFieldMaskStrict()},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Error("handler must not run")
+			},
+			status:   http.StatusBadRequest,
+			expected: `[{"path":"secret","reason":"unknown field"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := mizu.NewFieldMaskMiddleware[fieldMaskResponse](allowed, tt.opts...)(tt.handler)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, tt.header, recorder.Header().Get(mizu.HEADER_FIELD_MASK))
+			assert.Empty(t, recorder.Header().Get("Content-Length"))
+			assert.JSONEq(t, tt.expected, recorder.Body.String())
+		})
+	}
+}
+
+func TestMizu_WriteFieldMaskResponse(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := mizu.NewFieldMaskMiddleware[fieldMaskResponse]([]string{"age"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := fieldMaskResponse{DisplayName: "name", Age: 42}
+			require.NoError(t, mizu.WriteFieldMaskResponse(w, r, http.StatusAccepted, &value))
+			assert.NotZero(t, recorder.Body.Len(), "the response is written through, not buffered")
+		}))
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?fields=age", nil))
+
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, "age", recorder.Header().Get(mizu.HEADER_FIELD_MASK))
+	assert.JSONEq(t, `{"displayName":"","age":42}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	value := fieldMaskResponse{DisplayName: "name"}
+	require.NoError(t, mizu.WriteFieldMaskResponse(recorder, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, &value))
+	assert.Empty(t, recorder.Header().Get(mizu.HEADER_FIELD_MASK))
+	assert.JSONEq(t, `{"displayName":"name","age":0}`, recorder.Body.String())
+}
+
+func TestMizu_FilterFieldMaskResponse(t *testing.T) {
+	allowed := []string{"displayName", "age"}
+	profile := newFieldMaskProfile()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/?fields=age,tags", nil)
+	filtered, err := mizu.FilterFieldMaskResponse(recorder, request, allowed, &profile)
+	require.NoError(t, err)
+	assert.True(t, filtered)
+	assert.Equal(t, 42, profile.Age)
+	assert.Empty(t, profile.DisplayName)
+	assert.Equal(t, "age", recorder.Header().Get(mizu.HEADER_FIELD_MASK))
+
+	profile = newFieldMaskProfile()
+	_, err = mizu.FilterFieldMaskResponse(recorder, request, allowed, &profile, mizu.WithFieldMaskStrict())
+	var errs mizu.FieldMaskErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, mizu.FieldMaskErrors{{Path: "tags", Reason: "field is not allowed"}}, errs)
+	assert.Equal(t, "name", profile.DisplayName)
+
+	filtered, err = mizu.FilterFieldMaskResponse(recorder, httptest.NewRequest(http.MethodGet, "/", nil), allowed, &profile)
+	require.NoError(t, err)
+	assert.False(t, filtered)
+}
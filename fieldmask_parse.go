@@ -0,0 +1,147 @@
+package mizu
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ParseFieldMask parses a requested field selection and intersects it with
+// allowed, the paths a route is willing to expose. Three syntaxes are
+// accepted and may be mixed:
+//
+//   - Google partial response: `id,author(name,email),items/title`
+//   - dotted paths: `id,author.name,items.title`
+//   - OData `$select`: `id,author/name`
+//
+// Every requested path that does not reach the mask is returned with the
+// reason it was rejected, so callers may ignore the rejections or answer
+// 400 with them. A requested path covering several allowed paths narrows
+// to those paths and is not rejected.
+func ParseFieldMask[T any](allowed []string, raw string) (*FieldMask[T], FieldMaskErrors) {
+	requested, errs := parseFieldMaskExpr(raw)
+	typ := reflect.TypeFor[T]()
+	if !isFieldMaskStruct(typ) {
+		for _, path := range requested {
+			errs = append(errs, FieldMaskError{Path: path, Reason: "field mask type is not a JSON struct"})
+		}
+		return newFieldMask[T](nil), errs
+	}
+
+	allowed = validFieldMaskPaths(typ, allowed)
+	valid := make([]string, 0, len(requested))
+	for _, path := range requested {
+		switch {
+		case !validFieldMaskPath(typ, path):
+			errs = append(errs, FieldMaskError{Path: path, Reason: "unknown field"})
+		case len(intersectFieldMaskPaths(typ, allowed, []string{path})) == 0:
+			errs = append(errs, FieldMaskError{Path: path, Reason: "field is not allowed"})
+		default:
+			valid = append(valid, path)
+		}
+	}
+	return newFieldMask[T](intersectFieldMaskPaths(typ, allowed, valid)), errs
+}
+
+// ParseFieldMaskRequest parses the `fields` query parameter of r, falling
+// back to `$select`, with ParseFieldMask. Repeated parameters are joined.
+// It returns a nil mask when the request selects no fields, which callers
+// usually treat as "return everything".
+func ParseFieldMaskRequest[T any](r *http.Request, allowed []string) (*FieldMask[T], FieldMaskErrors) {
+	query := r.URL.Query()
+	values := query["fields"]
+	if len(values) == 0 {
+		values = query["$select"]
+	}
+	raw := strings.Join(slices.DeleteFunc(slices.Clone(values), func(value string) bool {
+		return strings.TrimSpace(value) == ""
+	}), ",")
+	if raw == "" {
+		return nil, nil
+	}
+	return ParseFieldMask[T](allowed, raw)
+}
+
+// parseFieldMaskExpr expands a selection expression into dotted paths.
+func parseFieldMaskExpr(raw string) ([]string, FieldMaskErrors) {
+	var paths []string
+	var errs FieldMaskErrors
+	items, ok := splitFieldMaskExpr(raw)
+	if !ok {
+		return nil, FieldMaskErrors{{Path: raw, Reason: "unbalanced parentheses"}}
+	}
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		head, inner, grouped := strings.Cut(item, "(")
+		if grouped && !strings.HasSuffix(inner, ")") || !grouped && strings.Contains(item, ")") {
+			errs = append(errs, FieldMaskError{Path: item, Reason: "unbalanced parentheses"})
+			continue
+		}
+		prefix, ok := parseFieldMaskSegments(head)
+		if !ok {
+			errs = append(errs, FieldMaskError{Path: item, Reason: "empty path segment"})
+			continue
+		}
+		if !grouped {
+			paths = append(paths, prefix)
+			continue
+		}
+
+		children, childErrs := parseFieldMaskExpr(strings.TrimSuffix(inner, ")"))
+		if len(children) == 0 && len(childErrs) == 0 {
+			errs = append(errs, FieldMaskError{Path: item, Reason: "empty sub-selection"})
+			continue
+		}
+		for _, child := range children {
+			paths = append(paths, prefix+"."+child)
+		}
+		for _, err := range childErrs {
+			errs = append(errs, FieldMaskError{Path: prefix + "(" + err.Path + ")", Reason: err.Reason})
+		}
+	}
+	return paths, errs
+}
+
+// splitFieldMaskExpr splits raw on the commas outside of parentheses.
+func splitFieldMaskExpr(raw string) ([]string, bool) {
+	var items []string
+	depth, start := 0, 0
+	for index, char := range raw {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return nil, false
+			}
+		case ',':
+			if depth == 0 {
+				items = append(items, raw[start:index])
+				start = index + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	return append(items, raw[start:]), true
+}
+
+// parseFieldMaskSegments joins a `/` or `.` separated path with dots.
+func parseFieldMaskSegments(raw string) (string, bool) {
+	segments := strings.FieldsFunc(raw, func(char rune) bool { return char == '/' || char == '.' })
+	if len(segments) == 0 || strings.Count(raw, "/")+strings.Count(raw, ".") != len(segments)-1 {
+		return "", false
+	}
+	for index, segment := range segments {
+		if segments[index] = strings.TrimSpace(segment); segments[index] == "" {
+			return "", false
+		}
+	}
+	return strings.Join(segments, "."), true
+}
@@ -0,0 +1,107 @@
+package mizu_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMizu_ParseFieldMask(t *testing.T) {
+	allowed := []string{"displayName", "age", "address", "items.label", "attributes"}
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected []string
+		rejected mizu.FieldMaskErrors
+	}{
+		{
+			name:     "partial response",
+			raw:      "displayName,address(city,zip),items/label",
+			expected: []string{"address.city", "address.zip", "displayName", "items.label"},
+		},
+		{
+			name:     "dotted",
+			raw:      "age, address.city ,items.label",
+			expected: []string{"address.city", "age", "items.label"},
+		},
+		{
+			name:     "select",
+			raw:      "address/zip,attributes/home/city",
+			expected: []string{"address.zip", "attributes.home.city"},
+		},
+		{
+			name:     "nested groups",
+			raw:      "attributes(home(city),work/zip)",
+			expected: []string{"attributes.home.city", "attributes.work.zip"},
+		},
+		{
+			name:     "narrowed",
+			raw:      "items",
+			expected: []string{"items.label"},
+		},
+		{
+			name:     "rejected",
+			raw:      "age,missing,items/count,address(city,nope),tags",
+			expected: []string{"age", "address.city"},
+			rejected: mizu.FieldMaskErrors{
+				{Path: "missing", Reason: "unknown field"},
+				{Path: "items.count", Reason: "field is not allowed"},
+				{Path: "address.nope", Reason: "unknown field"},
+				{Path: "tags", Reason: "field is not allowed"},
+			},
+		},
+		{
+			name: "malformed",
+			raw:  "age,address(),items//label,custom)",
+			rejected: mizu.FieldMaskErrors{
+				{Path: "age,address(),items//label,custom)", Reason: "unbalanced parentheses"},
+			},
+		},
+		{
+			name:     "malformed items",
+			raw:      "age,address(),items//label,address(city)x",
+			expected: []string{"age"},
+			rejected: mizu.FieldMaskErrors{
+				{Path: "address()", Reason: "empty sub-selection"},
+				{Path: "items//label", Reason: "empty path segment"},
+				{Path: "address(city)x", Reason: "unbalanced parentheses"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask, rejected := mizu.ParseFieldMask[fieldMaskProfile](allowed, tt.raw)
+			require.NotNil(t, mask)
+			assert.ElementsMatch(t, tt.expected, mask.Paths())
+			assert.Equal(t, tt.rejected, rejected)
+		})
+	}
+}
+
+func TestMizu_ParseFieldMaskRequest(t *testing.T) {
+	allowed := []string{"displayName", "age"}
+
+	request := httptest.NewRequest(http.MethodGet, "/profiles?fields=age&fields=displayName", nil)
+	mask, rejected := mizu.ParseFieldMaskRequest[fieldMaskProfile](request, allowed)
+	require.NotNil(t, mask)
+	assert.Empty(t, rejected)
+	assert.Equal(t, []string{"age", "displayName"}, mask.Paths())
+
+	request = httptest.NewRequest(http.MethodGet, "/profiles?$select=age,secret", nil)
+	mask, rejected = mizu.ParseFieldMaskRequest[fieldMaskProfile](request, allowed)
+	require.NotNil(t, mask)
+	assert.Equal(t, []string{"age"}, mask.Paths())
+	require.Len(t, rejected, 1)
+	assert.EqualError(t, rejected, `field mask path "secret": unknown field`)
+
+	request = httptest.NewRequest(http.MethodGet, "/profiles?fields=", nil)
+	mask, rejected = mizu.ParseFieldMaskRequest[fieldMaskProfile](request, allowed)
+	assert.Nil(t, mask)
+	assert.Nil(t, rejected)
+}
@@ -0,0 +1,508 @@
+package mizu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ApplyMergePatch applies an RFC 7396 JSON merge patch to value. Only
+// paths covered by allowed may be touched, every other path the patch
+// touches is rejected with FieldMaskErrors and value is left untouched.
+//
+// Patch objects descend into nested structs and maps, so `{"address":
+// {"city": "x"}}` touches `address.city` only, while `null` and non-object
+// members replace the whole field. It returns the mask of the fields whose
+// JSON representation actually changed. Fields hidden from JSON are kept.
+func ApplyMergePatch[T any](allowed *FieldMask[T], value *T, patch []byte) (*FieldMask[T], error) {
+	target, err := allowed.target(value, "merge patch")
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	if err := decodeFieldMaskPatch(patch, &document); err != nil {
+		return nil, fmt.Errorf("merge patch: %w", err)
+	}
+	object, ok := document.(map[string]any)
+	if !ok {
+		return nil, errors.New("merge patch: patch must be a JSON object")
+	}
+
+	var touched []string
+	var errs FieldMaskErrors
+	collectMergePatchPaths(target.Type(), nil, object, &touched, &errs)
+	return applyFieldMaskPatch(allowed, value, touched, errs, func(original any) (any, error) {
+		return mergePatchValue(original, object), nil
+	})
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch document to value. Every
+// `path`, `test` ones included, and the `from` of `move` and `copy`, is
+// resolved to a field mask path that must be covered by allowed,
+// otherwise the patch is rejected with
+// FieldMaskErrors and value is left untouched. Array indexes are
+// transparent, so `/items/0/label` needs `items.label` while adding or
+// removing elements needs `items`.
+//
+// It returns the mask of the fields whose JSON representation actually
+// changed. Fields hidden from JSON are kept.
+func ApplyJSONPatch[T any](allowed *FieldMask[T], value *T, patch []byte) (*FieldMask[T], error) {
+	target, err := allowed.target(value, "json patch")
+	if err != nil {
+		return nil, err
+	}
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("json patch: %w", err)
+	}
+
+	var touched []string
+	var errs FieldMaskErrors
+	for index, operation := range operations {
+		if err := operation.validate(); err != nil {
+			return nil, fmt.Errorf("json patch: operation %d: %w", index, err)
+		}
+		// `test` and the `from` of `copy` read the value at their pointer,
+		// which must be allowed as well lest they disclose hidden fields.
+		pointers := []string{operation.Path}
+		if operation.Op == "move" || operation.Op == "copy" {
+			pointers = append(pointers, operation.From)
+		}
+		for _, pointer := range pointers {
+			path, reason := resolveJSONPointerPath(target.Type(), pointer)
+			if reason != "" {
+				errs = append(errs, FieldMaskError{Path: pointer, Reason: reason})
+				continue
+			}
+			touched = append(touched, path)
+		}
+	}
+	return applyFieldMaskPatch(allowed, value, touched, errs, func(document any) (any, error) {
+		for index, operation := range operations {
+			var err error
+			if document, err = operation.apply(document); err != nil {
+				return nil, fmt.Errorf("json patch: operation %d: %w", index, err)
+			}
+		}
+		return document, nil
+	})
+}
+
+// applyFieldMaskPatch checks touched against allowed, patches the JSON
+// document of value and overwrites value with the fields that changed.
+func applyFieldMaskPatch[T any](
+	allowed *FieldMask[T], value *T, touched []string, errs FieldMaskErrors, patch func(any) (any, error),
+) (*FieldMask[T], error) {
+	for _, path := range touched {
+		if !slices.ContainsFunc(allowed.paths, func(prefix string) bool {
+			return matchFieldMaskPrefix(path, prefix)
+		}) {
+			errs = append(errs, FieldMaskError{Path: path, Reason: "field is not allowed"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	before, err := encodeFieldMaskPatch(value)
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	if err := decodeFieldMaskPatch(before, &document); err != nil {
+		return nil, err
+	}
+	if document, err = patch(document); err != nil {
+		return nil, err
+	}
+	patched, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var result T
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return nil, fmt.Errorf("decode patched value: %w", err)
+	}
+
+	// Compare the re-encoded result rather than the patched document, so
+	// that values the type normalizes, like zero values under omitempty,
+	// do not count as changes.
+	after, err := encodeFieldMaskPatch(&result)
+	if err != nil {
+		return nil, err
+	}
+	var beforeDocument, afterDocument any
+	_ = json.Unmarshal(before, &beforeDocument) // nolint: errcheck
+	_ = json.Unmarshal(after, &afterDocument)   // nolint: errcheck
+	changed := make([]string, 0, len(touched))
+	for _, path := range normalizeFieldMaskPaths(touched) {
+		parts := strings.Split(path, ".")
+		if !reflect.DeepEqual(fieldMaskDocumentValue(beforeDocument, parts), fieldMaskDocumentValue(afterDocument, parts)) {
+			changed = append(changed, path)
+		}
+	}
+
+	effective := newFieldMask[T](changed)
+	if err := effective.Overwrite(&result, value); err != nil {
+		return nil, err
+	}
+	return effective, nil
+}
+
+func encodeFieldMaskPatch(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+	return data, nil
+}
+
+func decodeFieldMaskPatch(data []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// collectMergePatchPaths lists the field mask paths touched by a merge
+// patch object applied on typ.
+func collectMergePatchPaths(
+	typ reflect.Type, prefix []string, patch map[string]any, touched *[]string, errs *FieldMaskErrors,
+) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	for _, name := range slices.Sorted(maps.Keys(patch)) {
+		path := append(slices.Clone(prefix), name)
+		var child reflect.Type
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := fieldMaskFieldsByName(typ)[name]
+			if !ok {
+				*errs = append(*errs, FieldMaskError{Path: strings.Join(path, "."), Reason: "unknown field"})
+				continue
+			}
+			child = field.typ
+		case reflect.Map:
+			child = typ.Elem()
+		}
+
+		if object, ok := patch[name].(map[string]any); ok && isFieldMaskObject(child) {
+			collectMergePatchPaths(child, path, object, touched, errs)
+			continue
+		}
+		*touched = append(*touched, strings.Join(path, "."))
+	}
+}
+
+// isFieldMaskObject reports whether a field mask path may descend into
+// typ, a struct or a string keyed map that is not JSON terminal.
+func isFieldMaskObject(typ reflect.Type) bool {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || isFieldMaskTerminal(typ) {
+		return false
+	}
+	return typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String
+}
+
+func mergePatchValue(target, patch any) any {
+	object, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	result, ok := target.(map[string]any)
+	if !ok {
+		result = make(map[string]any, len(object))
+	}
+	for name, value := range object {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+		result[name] = mergePatchValue(result[name], value)
+	}
+	return result
+}
+
+// resolveJSONPointerPath resolves a JSON pointer against typ to the field
+// mask path it touches. Array indexes are skipped and the path stops at
+// JSON terminal types.
+func resolveJSONPointerPath(typ reflect.Type, pointer string) (string, string) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return "", "malformed pointer"
+	}
+	if len(tokens) == 0 {
+		return "", "document root is not patchable"
+	}
+
+	path := make([]string, 0, len(tokens))
+walk:
+	for _, token := range tokens {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if isFieldMaskTerminal(typ) {
+			break
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := fieldMaskFieldsByName(typ)[token]
+			if !ok {
+				return "", "unknown field"
+			}
+			path = append(path, token)
+			typ = field.typ
+		case reflect.Map:
+			if typ.Key().Kind() != reflect.String {
+				return "", "unknown field"
+			}
+			path = append(path, token)
+			typ = typ.Elem()
+		case reflect.Array, reflect.Slice:
+			if _, err := strconv.Atoi(token); err != nil && token != "-" {
+				return "", "malformed array index"
+			}
+			typ = typ.Elem()
+		default:
+			break walk
+		}
+	}
+	return strings.Join(path, "."), ""
+}
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for index, token := range tokens {
+		tokens[index] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// fieldMaskDocumentValue projects a decoded JSON document on a field mask
+// path, mapping over arrays.
+func fieldMaskDocumentValue(document any, parts []string) any {
+	for index, part := range parts {
+		switch node := document.(type) {
+		case map[string]any:
+			document = node[part]
+		case []any:
+			projected := make([]any, len(node))
+			for i, item := range node {
+				projected[i] = fieldMaskDocumentValue(item, parts[index:])
+			}
+			return projected
+		default:
+			return nil
+		}
+	}
+	return document
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (o jsonPatchOperation) validate() error {
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value == nil {
+			return fmt.Errorf("%s requires a value", o.Op)
+		}
+	case "remove", "move", "copy":
+	default:
+		return fmt.Errorf("unknown op %q", o.Op)
+	}
+	if _, err := parseJSONPointer(o.Path); err != nil {
+		return err
+	}
+	_, err := parseJSONPointer(o.From)
+	return err
+}
+
+func (o jsonPatchOperation) apply(document any) (any, error) {
+	path, _ := parseJSONPointer(o.Path)
+	from, _ := parseJSONPointer(o.From)
+
+	switch o.Op {
+	case "add", "replace":
+		var value any
+		if err := decodeFieldMaskPatch(o.Value, &value); err != nil {
+			return nil, err
+		}
+		return updateJSONPointer(document, path, o.Op, value)
+	case "remove":
+		return updateJSONPointer(document, path, "remove", nil)
+	case "move", "copy":
+		if o.Op == "move" && len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		value, err := lookupJSONPointer(document, from)
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == "move" {
+			if document, err = updateJSONPointer(document, from, "remove", nil); err != nil {
+				return nil, err
+			}
+		} else {
+			var clone any
+			data, _ := json.Marshal(value)
+			_ = decodeFieldMaskPatch(data, &clone) // nolint: errcheck
+			value = clone
+		}
+		return updateJSONPointer(document, path, "add", value)
+	case "test":
+		var expected any
+		if err := decodeFieldMaskPatch(o.Value, &expected); err != nil {
+			return nil, err
+		}
+		actual, err := lookupJSONPointer(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSONPatchValue(actual, expected) {
+			return nil, fmt.Errorf("test failed at %q", o.Path)
+		}
+	}
+	return document, nil
+}
+
+func lookupJSONPointer(document any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := document.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			document = value
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("index %q is out of range", token)
+			}
+			document = node[index]
+		default:
+			return nil, fmt.Errorf("cannot traverse %q on a scalar", token)
+		}
+	}
+	return document, nil
+}
+
+// updateJSONPointer adds, replaces or removes the value at tokens and
+// returns the updated document, as arrays may be reallocated.
+func updateJSONPointer(document any, tokens []string, op string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("document root is not patchable")
+	}
+	token := tokens[0]
+	switch node := document.(type) {
+	case map[string]any:
+		current, ok := node[token]
+		switch {
+		case len(tokens) > 1:
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			updated, err := updateJSONPointer(current, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			node[token] = updated
+		case op == "remove" || op == "replace":
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			if op == "remove" {
+				delete(node, token)
+			} else {
+				node[token] = value
+			}
+		default:
+			node[token] = value
+		}
+		return node, nil
+	case []any:
+		if len(tokens) == 1 && op == "add" {
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = strconv.Atoi(token); err != nil || index < 0 || index > len(node) {
+					return nil, fmt.Errorf("index %q is out of range", token)
+				}
+			}
+			return slices.Insert(node, index, value), nil
+		}
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(node) {
+			return nil, fmt.Errorf("index %q is out of range", token)
+		}
+		switch {
+		case len(tokens) > 1:
+			if node[index], err = updateJSONPointer(node[index], tokens[1:], op, value); err != nil {
+				return nil, err
+			}
+		case op == "remove":
+			return slices.Delete(node, index, index+1), nil
+		default:
+			node[index] = value
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot traverse %q on a scalar", token)
+}
+
+// equalJSONPatchValue compares decoded JSON values, numbers by value.
+func equalJSONPatchValue(left, right any) bool {
+	switch left := left.(type) {
+	case json.Number:
+		right, ok := right.(json.Number)
+		if !ok {
+			return false
+		}
+		leftFloat, leftErr := left.Float64()
+		rightFloat, rightErr := right.Float64()
+		return left == right || leftErr == nil && rightErr == nil && leftFloat == rightFloat
+	case map[string]any:
+		right, ok := right.(map[string]any)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for name, value := range left {
+			other, ok := right[name]
+			if !ok || !equalJSONPatchValue(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		right, ok := right.([]any)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for index := range left {
+			if !equalJSONPatchValue(left[index], right[index]) {
+				return false
+			}
+		}
+		return true
+	}
+	return left == right
+}
@@ -0,0 +1,183 @@
+package mizu_test
+
+import (
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMaskPatchDocument struct {
+	FieldMaskEmbedded
+	DisplayName string                      `json:"displayName"`
+	Age         int                         `json:"age,omitempty"`
+	Address     *fieldMaskAddress           `json:"address,omitempty"`
+	Items       []fieldMaskItem             `json:"items"`
+	Attributes  map[string]fieldMaskAddress `json:"attributes,omitempty"`
+	Secret      string                      `json:"-"`
+}
+
+func newFieldMaskPatchDocument() fieldMaskPatchDocument {
+	return fieldMaskPatchDocument{
+		FieldMaskEmbedded: FieldMaskEmbedded{Embedded: "embedded"},
+		DisplayName:       "name",
+		Age:               42,
+		Address:           &fieldMaskAddress{City: "Shanghai", Zip: "200000"},
+		Items:             []fieldMaskItem{{Label: "first", Count: 1}, {Label: "second", Count: 2}},
+		Attributes:        map[string]fieldMaskAddress{"home": {City: "Hangzhou", Zip: "310000"}},
+		Secret:            "secret",
+	}
+}
+
+func TestMizu_ApplyMergePatch(t *testing.T) {
+	allowed := mizu.Intersect[fieldMaskPatchDocument](
+		[]string{"displayName", "age", "address.city", "items", "attributes", "embedded"},
+		[]string{"displayName", "age", "address", "items", "attributes", "embedded"},
+	)
+
+	value := newFieldMaskPatchDocument()
+	changed, err := mizu.ApplyMergePatch(allowed, &value, []byte(`{
+		"displayName": "name",
+		"age": null,
+		"address": {"city": "Beijing"},
+		"attributes": {"home": null, "work": {"city": "Shenzhen"}},
+		"embedded": "patched"
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"address.city", "age", "attributes.home", "attributes.work.city", "embedded"}, changed.Paths())
+
+	expected := newFieldMaskPatchDocument()
+	expected.Embedded = "patched"
+	expected.Age = 0
+	expected.Address.City = "Beijing"
+	expected.Attributes = map[string]fieldMaskAddress{"work": {City: "Shenzhen"}}
+	assert.Equal(t, expected, value, "unchanged and hidden fields are kept")
+
+	tests := []struct {
+		name     string
+		patch    string
+		rejected mizu.FieldMaskErrors
+	}{
+		{
+			name:     "disallowed leaf",
+			patch:    `{"address": {"zip": "100000"}}`,
+			rejected: mizu.FieldMaskErrors{{Path: "address.zip", Reason: "field is not allowed"}},
+		},
+		{
+			name:     "disallowed parent",
+			patch:    `{"address": null}`,
+			rejected: mizu.FieldMaskErrors{{Path: "address", Reason: "field is not allowed"}},
+		},
+		{
+			name:  "unknown",
+			patch: `{"secret": "leaked", "address": {"street": "x"}}`,
+			rejected: mizu.FieldMaskErrors{
+				{Path: "address.street", Reason: "unknown field"},
+				{Path: "secret", Reason: "unknown field"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := newFieldMaskPatchDocument()
+			_, err := mizu.ApplyMergePatch(allowed, &value, []byte(tt.patch))
+			assert.Equal(t, tt.rejected, err)
+			assert.Equal(t, newFieldMaskPatchDocument(), value)
+		})
+	}
+
+	_, err = mizu.ApplyMergePatch(allowed, &value, []byte(`["age"]`))
+	assert.EqualError(t, err, "merge patch: patch must be a JSON object")
+}
+
+func TestMizu_ApplyJSONPatch(t *testing.T) {
+	allowed := mizu.Intersect[fieldMaskPatchDocument](
+		[]string{"displayName", "items.label", "attributes", "address.city"},
+		[]string{"displayName", "items", "attributes", "address"},
+	)
+
+	value := newFieldMaskPatchDocument()
+	changed, err := mizu.ApplyJSONPatch(allowed, &value, []byte(`[
+		{"op": "test", "path": "/displayName", "value": "name"},
+		{"op": "replace", "path": "/items/1/label", "value": "renamed"},
+		{"op": "replace", "path": "/items/0/label", "value": "first"},
+		{"op": "add", "path": "/attributes/work", "value": {"city": "Shenzhen"}},
+		{"op": "copy", "from": "/address/city", "path": "/displayName"},
+		{"op": "move", "from": "/attributes/home", "path": "/attributes/old~1home"}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"attributes.home", "attributes.old/home", "attributes.work", "displayName", "items.label"}, changed.Paths())
+
+	expected := newFieldMaskPatchDocument()
+	expected.DisplayName = "Shanghai"
+	expected.Items[1].Label = "renamed"
+	expected.Attributes = map[string]fieldMaskAddress{
+		"old/home": {City: "Hangzhou", Zip: "310000"},
+		"work":     {City: "Shenzhen"},
+	}
+	assert.Equal(t, expected, value)
+
+	tests := []struct {
+		name     string
+		patch    string
+		rejected mizu.FieldMaskErrors
+		err      string
+	}{
+		{
+			name:     "append needs the array",
+			patch:    `[{"op": "add", "path": "/items/-", "value": {"label": "third"}}]`,
+			rejected: mizu.FieldMaskErrors{{Path: "items", Reason: "field is not allowed"}},
+		},
+		{
+			name:     "move needs both ends",
+			patch:    `[{"op": "move", "from": "/address/zip", "path": "/address/city"}]`,
+			rejected: mizu.FieldMaskErrors{{Path: "address.zip", Reason: "field is not allowed"}},
+		},
+		{
+			name:     "copy needs its source",
+			patch:    `[{"op": "copy", "from": "/age", "path": "/displayName"}]`,
+			rejected: mizu.FieldMaskErrors{{Path: "age", Reason: "field is not allowed"}},
+		},
+		{
+			name:     "test needs its path",
+			patch:    `[{"op": "test", "path": "/age", "value": 42}]`,
+			rejected: mizu.FieldMaskErrors{{Path: "age", Reason: "field is not allowed"}},
+		},
+		{
+			name:  "malformed",
+			patch: `[{"op": "remove", "path": "/items/first"}, {"op": "remove", "path": ""}]`,
+			rejected: mizu.FieldMaskErrors{
+				{Path: "/items/first", Reason: "malformed array index"},
+				{Path: "", Reason: "document root is not patchable"},
+			},
+		},
+		{
+			name:  "failed test",
+			patch: `[{"op": "replace", "path": "/displayName", "value": "x"}, {"op": "test", "path": "/displayName", "value": "name"}]`,
+			err:   `json patch: operation 1: test failed at "/displayName"`,
+		},
+		{
+			name:  "missing member",
+			patch: `[{"op": "replace", "path": "/attributes/none", "value": {}}]`,
+			err:   `json patch: operation 0: member "none" does not exist`,
+		},
+		{
+			name:  "unknown op",
+			patch: `[{"op": "merge", "path": "/displayName"}]`,
+			err:   `json patch: operation 0: unknown op "merge"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := newFieldMaskPatchDocument()
+			_, err := mizu.ApplyJSONPatch(allowed, &value, []byte(tt.patch))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.Equal(t, tt.rejected, err)
+			}
+			assert.Equal(t, newFieldMaskPatchDocument(), value)
+		})
+	}
+}
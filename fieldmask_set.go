@@ -0,0 +1,292 @@
+package mizu
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Union returns a field mask selecting what either mask selects.
+func (m *FieldMask[T]) Union(other *FieldMask[T]) *FieldMask[T] {
+	return newFieldMask[T](slices.Concat(m.Paths(), other.Paths()))
+}
+
+// Subtract returns a field mask selecting what m selects and other does
+// not. Parent paths are expanded into the sibling fields left over, and
+// selections no path can express, such as every map key but one, are left
+// out so that the result never selects a field of other.
+func (m *FieldMask[T]) Subtract(other *FieldMask[T]) *FieldMask[T] {
+	return newFieldMask[T](subtractFieldMaskPaths(reflect.TypeFor[T](), m.Paths(), other.Paths(), false))
+}
+
+// Contains reports whether the mask selects every field path selects. It
+// is false for paths that are not valid for T.
+func (m *FieldMask[T]) Contains(path string) bool {
+	typ := reflect.TypeFor[T]()
+	if !isFieldMaskStruct(typ) || !validFieldMaskPath(typ, path) {
+		return false
+	}
+	return len(subtractFieldMaskPaths(typ, []string{path}, m.Paths(), true)) == 0
+}
+
+// Covers reports whether the mask selects every field other selects.
+func (m *FieldMask[T]) Covers(other *FieldMask[T]) bool {
+	return len(subtractFieldMaskPaths(reflect.TypeFor[T](), other.Paths(), m.Paths(), true)) == 0
+}
+
+// ExpandLeaves returns an equivalent field mask whose paths descend through
+// nested structs, and slices or maps of structs, down to leaf fields, such
+// as `address.city` and `address.zip` for `address`. Recursive types are
+// expanded once.
+func (m *FieldMask[T]) ExpandLeaves() *FieldMask[T] {
+	typ := reflect.TypeFor[T]()
+	leaves := make([]string, 0, len(m.Paths()))
+	for _, path := range m.Paths() {
+		leaves = append(leaves, expandFieldMaskLeaves(typ, path, nil)...)
+	}
+	return newFieldMask[T](leaves)
+}
+
+func subtractFieldMaskPaths(typ reflect.Type, paths, others []string, keep bool) []string {
+	if !isFieldMaskStruct(typ) {
+		return nil
+	}
+	explicit := make([]string, len(others))
+	for index, other := range others {
+		explicit[index] = explicitFieldMaskPath(typ, other)
+	}
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		explicitPath := explicitFieldMaskPath(typ, path)
+		remaining := subtractFieldMaskPath(typ, explicitPath, explicit, keep)
+		if len(remaining) == 1 && remaining[0] == explicitPath {
+			remaining[0] = path
+		}
+		result = append(result, remaining...)
+	}
+	return result
+}
+
+// subtractFieldMaskPath removes others from path, expanding path while an
+// other path is narrower. Overlaps that cannot be expressed keep path when
+// keep is set and drop it otherwise.
+func subtractFieldMaskPath(typ reflect.Type, path string, others []string, keep bool) []string {
+	depth := strings.Count(path, ".")
+	overlaps, narrower := false, false
+	for _, other := range others {
+		if matchFieldMaskPrefix(path, other) {
+			return nil
+		}
+		if _, ok := meetFieldMaskPaths(path, other); ok {
+			overlaps = true
+			narrower = narrower || strings.Count(other, ".") > depth
+		}
+	}
+	if !overlaps {
+		return []string{path}
+	}
+
+	children, wildcard := expandFieldMaskPath(typ, path, false)
+	if (wildcard || narrower) && len(children) > 0 {
+		result := make([]string, 0, len(children))
+		for _, child := range children {
+			result = append(result, subtractFieldMaskPath(typ, child, others, keep)...)
+		}
+		return result
+	}
+	if keep {
+		return []string{path}
+	}
+	return nil
+}
+
+func expandFieldMaskLeaves(typ reflect.Type, path string, seen []reflect.Type) []string {
+	children, wildcard := expandFieldMaskPath(typ, path, true)
+	if wildcard {
+		leaves := make([]string, 0, len(children))
+		for _, child := range children {
+			leaves = append(leaves, expandFieldMaskLeaves(typ, child, seen)...)
+		}
+		return leaves
+	}
+
+	if len(children) == 1 && children[0] == path+"."+_FIELD_MASK_WILDCARD {
+		return expandFieldMaskLeaves(typ, children[0], seen)
+	}
+
+	parent := fieldMaskStructAt(typ, path)
+	if len(children) == 0 || parent != nil && slices.Contains(seen, parent) {
+		return []string{path}
+	}
+	if parent != nil {
+		seen = append(seen[:len(seen):len(seen)], parent)
+	}
+	leaves := make([]string, 0, len(children))
+	for _, child := range children {
+		leaves = append(leaves, expandFieldMaskLeaves(typ, child, seen)...)
+	}
+	return leaves
+}
+
+// expandFieldMaskPath returns the paths one level below path that together
+// select the same fields. If path has a wildcard over struct fields, the
+// first one is replaced by each field instead and wildcard is true.
+// Transparent expansion passes through slices of structs and leaves other
+// slices and maps alone, otherwise slices and maps expand to `*`.
+func expandFieldMaskPath(root reflect.Type, path string, transparent bool) ([]string, bool) {
+	parts := strings.Split(path, ".")
+	typ := root
+	for index := 0; index < len(parts); {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			if parts[index] == _FIELD_MASK_WILDCARD {
+				var paths []string
+				for _, field := range fieldMaskFields(typ) {
+					candidate := strings.Join(slices.Concat(parts[:index], []string{field.name}, parts[index+1:]), ".")
+					if validFieldMaskPath(root, candidate) {
+						paths = append(paths, candidate)
+					}
+				}
+				return paths, true
+			}
+			field, ok := fieldMaskFieldsByName(typ)[parts[index]]
+			if !ok {
+				return nil, false
+			}
+			typ = field.typ
+			index++
+		case reflect.Array, reflect.Slice:
+			if _, ok := parseFieldMaskIndex(parts[index]); ok {
+				index++
+			}
+			typ = typ.Elem()
+		case reflect.Map:
+			typ = typ.Elem()
+			index++
+		default:
+			return nil, false
+		}
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if isFieldMaskTerminal(typ) {
+		return nil, false
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields := fieldMaskFields(typ)
+		paths := make([]string, 0, len(fields))
+		for _, field := range fields {
+			paths = append(paths, path+"."+field.name)
+		}
+		return paths, false
+	case reflect.Array, reflect.Slice:
+		if !transparent {
+			return []string{path + "." + _FIELD_MASK_WILDCARD}, false
+		}
+		if elem := fieldMaskStructType(typ.Elem()); elem != nil {
+			fields := fieldMaskFields(elem)
+			paths := make([]string, 0, len(fields))
+			for _, field := range fields {
+				paths = append(paths, path+"."+field.name)
+			}
+			return paths, false
+		}
+	case reflect.Map:
+		if typ.Key().Kind() == reflect.String && (!transparent || fieldMaskStructType(typ.Elem()) != nil) {
+			return []string{path + "." + _FIELD_MASK_WILDCARD}, false
+		}
+	}
+	return nil, false
+}
+
+// explicitFieldMaskPath adds a wildcard after every slice or array that
+// path passes through, so that paths with and without element segments
+// line up.
+func explicitFieldMaskPath(typ reflect.Type, path string) string {
+	parts := strings.Split(path, ".")
+	explicit := make([]string, 0, len(parts))
+	for index := 0; index < len(parts); {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := fieldMaskFieldsByName(typ)[parts[index]]
+			if !ok {
+				return strings.Join(append(explicit, parts[index:]...), ".")
+			}
+			typ = field.typ
+		case reflect.Array, reflect.Slice:
+			typ = typ.Elem()
+			if _, ok := parseFieldMaskIndex(parts[index]); !ok {
+				explicit = append(explicit, _FIELD_MASK_WILDCARD)
+				continue
+			}
+		case reflect.Map:
+			typ = typ.Elem()
+		default:
+			return strings.Join(append(explicit, parts[index:]...), ".")
+		}
+		explicit = append(explicit, parts[index])
+		index++
+	}
+	return strings.Join(explicit, ".")
+}
+
+// fieldMaskStructAt returns the struct type selected by path, or the
+// element struct type of the slice or map it selects, or nil.
+func fieldMaskStructAt(typ reflect.Type, path string) reflect.Type {
+	parts := strings.Split(path, ".")
+	for index := 0; index < len(parts); {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := fieldMaskFieldsByName(typ)[parts[index]]
+			if !ok {
+				return nil
+			}
+			typ = field.typ
+			index++
+		case reflect.Array, reflect.Slice:
+			if _, ok := parseFieldMaskIndex(parts[index]); ok {
+				index++
+			}
+			typ = typ.Elem()
+		case reflect.Map:
+			typ = typ.Elem()
+			index++
+		default:
+			return nil
+		}
+	}
+	if structType := fieldMaskStructType(typ); structType != nil {
+		return structType
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Array || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		return fieldMaskStructType(typ.Elem())
+	}
+	return nil
+}
+
+// fieldMaskStructType dereferences typ and returns it if it is a struct
+// that field masks descend into.
+func fieldMaskStructType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if !isFieldMaskStruct(typ) {
+		return nil
+	}
+	return typ
+}
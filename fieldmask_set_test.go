@@ -0,0 +1,139 @@
+package mizu_test
+
+import (
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMaskTree struct {
+	Name     string          `json:"name"`
+	Children []fieldMaskTree `json:"children"`
+}
+
+func mustFieldMask(t *testing.T, paths ...string) *mizu.FieldMask[fieldMaskProfile] {
+	t.Helper()
+
+	mask, err := mizu.NewFieldMask[fieldMaskProfile](paths...)
+	require.NoError(t, err)
+	return mask
+}
+
+func TestMizu_NewFieldMask(t *testing.T) {
+	mask, err := mizu.NewFieldMask[fieldMaskProfile]("address.city", "address", "items.0.label", "attributes.*.zip")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"address", "attributes.*.zip", "items.0.label"}, mask.Paths())
+
+	_, err = mizu.NewFieldMask[fieldMaskProfile](
+		"displayName", "", "address..city", "missing", "custom.nested", "Secret", "fixed.2",
+	)
+	assert.Equal(t, mizu.FieldMaskErrors{
+		{Path: "", Reason: "malformed path"},
+		{Path: "address..city", Reason: "malformed path"},
+		{Path: "missing", Reason: "unknown field"},
+		{Path: "custom.nested", Reason: "unknown field"},
+		{Path: "Secret", Reason: "unknown field"},
+		{Path: "fixed.2", Reason: "index out of range"},
+	}, err)
+
+	_, err = mizu.NewFieldMask[fieldMaskCollision]("direct", "Shared")
+	assert.Equal(t, mizu.FieldMaskErrors{{Path: "Shared", Reason: "ambiguous field"}}, err)
+
+	_, err = mizu.NewFieldMask[int]("value")
+	assert.Equal(t, mizu.FieldMaskErrors{{Path: "value", Reason: "field mask type is not a JSON struct"}}, err)
+}
+
+func TestMizu_FieldMaskUnion(t *testing.T) {
+	union := mustFieldMask(t, "address.city", "items.label").Union(mustFieldMask(t, "address", "age"))
+	assert.Equal(t, []string{"address", "age", "items.label"}, union.Paths())
+
+	var empty *mizu.FieldMask[fieldMaskProfile]
+	assert.Equal(t, []string{"age"}, empty.Union(mustFieldMask(t, "age")).Paths())
+}
+
+func TestMizu_FieldMaskSubtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		left  []string
+		right []string
+		want  []string
+	}{
+		{
+			name:  "disjoint",
+			left:  []string{"age", "address.city"},
+			right: []string{"displayName"},
+			want:  []string{"address.city", "age"},
+		},
+		{
+			name:  "covered",
+			left:  []string{"address.city", "items.label"},
+			right: []string{"address", "items"},
+			want:  []string{},
+		},
+		{
+			name:  "parent expands to siblings",
+			left:  []string{"address", "items"},
+			right: []string{"address.city", "items.label"},
+			want:  []string{"address.zip", "items.*.count"},
+		},
+		{
+			name:  "elements",
+			left:  []string{"items.*.label", "fixed"},
+			right: []string{"items.1.label", "fixed.0"},
+			want:  []string{},
+		},
+		{
+			name:  "wildcard map keys",
+			left:  []string{"attributes"},
+			right: []string{"attributes.*.zip"},
+			want:  []string{"attributes.*.city"},
+		},
+		{
+			name:  "single map key is not expressible",
+			left:  []string{"attributes.*.city", "age"},
+			right: []string{"attributes.home"},
+			want:  []string{"age"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			left, right := mustFieldMask(t, tc.left...), mustFieldMask(t, tc.right...)
+			assert.Equal(t, tc.want, left.Subtract(right).Paths())
+		})
+	}
+}
+
+func TestMizu_FieldMaskContainsAndCovers(t *testing.T) {
+	mask := mustFieldMask(t, "address.city", "address.zip", "items.label", "attributes.*.city")
+
+	assert.True(t, mask.Contains("address"), "all leaves of address are selected")
+	assert.True(t, mask.Contains("items.0.label"))
+	assert.True(t, mask.Contains("attributes.home.city"))
+	assert.False(t, mask.Contains("items"))
+	assert.False(t, mask.Contains("attributes.home"))
+	assert.False(t, mask.Contains("missing"))
+
+	assert.True(t, mask.Covers(mustFieldMask(t, "address", "items.1.label")))
+	assert.False(t, mask.Covers(mustFieldMask(t, "address", "age")))
+	assert.True(t, mask.Covers(mustFieldMask(t)))
+	assert.False(t, mustFieldMask(t, "attributes.home").Covers(mustFieldMask(t, "attributes.*.city")))
+}
+
+func TestMizu_FieldMaskExpandLeaves(t *testing.T) {
+	mask := mustFieldMask(t, "address", "items", "attributes", "tags", "custom", "age")
+	assert.Equal(t, []string{
+		"address.city", "address.zip",
+		"age",
+		"attributes.*.city", "attributes.*.zip",
+		"custom",
+		"items.count", "items.label",
+		"tags",
+	}, mask.ExpandLeaves().Paths())
+
+	tree, err := mizu.NewFieldMask[fieldMaskTree]("children")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"children.children", "children.name"}, tree.ExpandLeaves().Paths())
+}
@@ -0,0 +1,183 @@
+package mizu
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	_SQL_VALUER_TYPE  = reflect.TypeFor[driver.Valuer]()
+	_SQL_SCANNER_TYPE = reflect.TypeFor[sql.Scanner]()
+)
+
+// ProjectionOption configures how field mask paths map to storage
+// columns.
+type ProjectionOption func(*projectionConfig)
+
+type projectionConfig struct {
+	tag       string
+	separator string
+}
+
+// WithProjectionTag sets the struct tag naming storage columns. The
+// default is `db`.
+func WithProjectionTag(tag string) ProjectionOption {
+	return func(c *projectionConfig) {
+		c.tag = tag
+	}
+}
+
+// WithProjectionSeparator sets the separator joining the column of a
+// nested struct with the columns of its fields. The default is `_`.
+func WithProjectionSeparator(separator string) ProjectionOption {
+	return func(c *projectionConfig) {
+		c.separator = separator
+	}
+}
+
+// Projection is the storage side of a field mask: the selected columns in
+// struct declaration order and, for Project, their values in the same
+// order.
+type Projection struct {
+	Columns []string
+	Values  []any
+}
+
+// SetClause renders the columns as `a = ?, b = ?` for an UPDATE statement
+// with question mark placeholders.
+func (p *Projection) SetClause() string {
+	assignments := make([]string, len(p.Columns))
+	for index, column := range p.Columns {
+		assignments[index] = column + " = ?"
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// SetClauseNumbered renders the columns as `a = $1, b = $2` for an UPDATE
+// statement with numbered placeholders starting at start.
+func (p *Projection) SetClauseNumbered(start int) string {
+	assignments := make([]string, len(p.Columns))
+	for index, column := range p.Columns {
+		assignments[index] = column + " = $" + strconv.Itoa(start+index)
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// Columns returns the storage columns selected by the mask, in struct
+// declaration order, for a partial SELECT.
+//
+// Columns are named by the projection tag. Fields without the tag, or
+// tagged `-`, are not stored. Nested structs are flattened: with
+// `db:"address"` on the field, its `db:"city"` field is stored as
+// `address_city`, unless the struct implements driver.Valuer, sql.Scanner
+// or a JSON marshaler, in which case it is a single column. Paths below a
+// column, such as into slices and maps, select the whole column. Paths
+// selecting no column are returned as FieldMaskErrors.
+func (m *FieldMask[T]) Columns(opts ...ProjectionOption) ([]string, error) {
+	projection, err := m.project(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return projection.Columns, nil
+}
+
+// Project returns the storage columns selected by the mask together with
+// their values in value, ready for a partial UPDATE:
+//
+//	projection, err := mask.Project(&user)
+//	_, err = db.ExecContext(ctx, "UPDATE users SET "+projection.SetClause()+" WHERE id = ?", append(projection.Values, user.Id)...)
+//
+// Values under a nil pointer are nil. See Columns for the column mapping.
+func (m *FieldMask[T]) Project(value *T, opts ...ProjectionOption) (*Projection, error) {
+	target, err := m.target(value, "project")
+	if err != nil {
+		return nil, err
+	}
+	return m.project(&target, opts)
+}
+
+func (m *FieldMask[T]) project(value *reflect.Value, opts []ProjectionOption) (*Projection, error) {
+	if _, err := m.target(new(T), "project"); err != nil {
+		return nil, err
+	}
+	config := &projectionConfig{tag: "db", separator: "_"}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	columns := collectStorageColumns(m.typ, config, "", nil, nil)
+	matched := make([]bool, len(m.paths))
+	projection := &Projection{Columns: make([]string, 0, len(columns))}
+	for _, column := range columns {
+		selected := false
+		for index, path := range m.paths {
+			if _, ok := meetFieldMaskPaths(explicitFieldMaskPath(m.typ, path), column.path); ok {
+				selected, matched[index] = true, true
+			}
+		}
+		if !selected {
+			continue
+		}
+		projection.Columns = append(projection.Columns, column.name)
+		if value != nil {
+			projection.Values = append(projection.Values, fieldMaskPathValue(*value, strings.Split(column.path, ".")))
+		}
+	}
+
+	var errs FieldMaskErrors
+	for index, path := range m.paths {
+		if !matched[index] {
+			errs = append(errs, FieldMaskError{Path: path, Reason: "no storage column"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return projection, nil
+}
+
+type storageColumn struct {
+	name string
+	path string
+}
+
+func collectStorageColumns(
+	typ reflect.Type, config *projectionConfig, prefix string, path []string, seen []reflect.Type,
+) []storageColumn {
+	columns := make([]storageColumn, 0)
+	for _, field := range fieldMaskFields(typ) {
+		tag := typ.FieldByIndex(field.index).Tag.Get(config.tag)
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldPath := append(path[:len(path):len(path)], field.name)
+		if nested := storageStructType(field.typ); nested != nil && !slices.Contains(seen, nested) {
+			columns = append(columns, collectStorageColumns(
+				nested, config, prefix+name+config.separator, fieldPath, append(seen, typ),
+			)...)
+			continue
+		}
+		columns = append(columns, storageColumn{name: prefix + name, path: strings.Join(fieldPath, ".")})
+	}
+	return columns
+}
+
+// storageStructType dereferences typ and returns it if it is a struct
+// stored as flattened columns.
+func storageStructType(typ reflect.Type) reflect.Type {
+	structType := fieldMaskStructType(typ)
+	if structType == nil {
+		return nil
+	}
+	for _, candidate := range []reflect.Type{structType, reflect.PointerTo(structType)} {
+		if candidate.Implements(_SQL_VALUER_TYPE) || candidate.Implements(_SQL_SCANNER_TYPE) {
+			return nil
+		}
+	}
+	return structType
+}
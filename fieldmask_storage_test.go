@@ -0,0 +1,71 @@
+package mizu_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMaskStorageAddress struct {
+	City string `json:"city" db:"city"`
+	Zip  string `json:"zip" db:"zip"`
+}
+
+type fieldMaskStorageRow struct {
+	Id       int64                    `json:"id" db:"id"`
+	Name     string                   `json:"name" db:"name"`
+	Address  *fieldMaskStorageAddress `json:"address" db:"address"`
+	Billing  fieldMaskStorageAddress  `json:"billing" db:"bill,omitempty"`
+	Tags     []string                 `json:"tags" db:"tags"`
+	Nickname sql.NullString           `json:"nickname" db:"nickname"`
+	Computed string                   `json:"computed"`
+	Ignored  string                   `json:"ignored" db:"-"`
+}
+
+func TestMizu_FieldMaskColumns(t *testing.T) {
+	mask, err := mizu.NewFieldMask[fieldMaskStorageRow]("tags.0", "address", "name", "billing.zip", "nickname")
+	require.NoError(t, err)
+
+	columns, err := mask.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "address_city", "address_zip", "bill_zip", "tags", "nickname"}, columns)
+
+	columns, err = mask.Columns(mizu.WithProjectionSeparator("."))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "address.city", "address.zip", "bill.zip", "tags", "nickname"}, columns)
+
+	columns, err = mask.Columns(mizu.WithProjectionTag("json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "address_city", "address_zip", "billing_zip", "tags", "nickname"}, columns)
+
+	mask, err = mizu.NewFieldMask[fieldMaskStorageRow]("name", "computed", "ignored")
+	require.NoError(t, err)
+	_, err = mask.Columns()
+	assert.Equal(t, mizu.FieldMaskErrors{
+		{Path: "computed", Reason: "no storage column"},
+		{Path: "ignored", Reason: "no storage column"},
+	}, err)
+}
+
+func TestMizu_FieldMaskProject(t *testing.T) {
+	mask, err := mizu.NewFieldMask[fieldMaskStorageRow]("billing", "address.zip", "name")
+	require.NoError(t, err)
+
+	row := fieldMaskStorageRow{
+		Id:      1,
+		Name:    "mizu",
+		Billing: fieldMaskStorageAddress{City: "Shanghai", Zip: "200000"},
+	}
+	projection, err := mask.Project(&row)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "address_zip", "bill_city", "bill_zip"}, projection.Columns)
+	assert.Equal(t, []any{"mizu", nil, "Shanghai", "200000"}, projection.Values)
+	assert.Equal(t, "name = ?, address_zip = ?, bill_city = ?, bill_zip = ?", projection.SetClause())
+	assert.Equal(t, "name = $2, address_zip = $3, bill_city = $4, bill_zip = $5", projection.SetClauseNumbered(2))
+
+	_, err = mask.Project(nil)
+	assert.Error(t, err)
+}
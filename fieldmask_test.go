@@ -150,6 +150,30 @@ func TestMizu_FieldMaskIntersect(t *testing.T) {
 			requested: []string{"items.label", "attributes.home.city", "embedded"},
 			want:      []string{"attributes.home.city", "embedded", "items.label"},
 		},
+		{
+			name:      "implicit and wildcard elements",
+			allowed:   []string{"items.*.label"},
+			requested: []string{"items.label"},
+			want:      []string{"items.label"},
+		},
+		{
+			name:      "wildcard and implicit elements",
+			allowed:   []string{"items.label"},
+			requested: []string{"items.*.label"},
+			want:      []string{"items.*.label"},
+		},
+		{
+			name:      "implicit and indexed elements",
+			allowed:   []string{"items.label", "fixed.label"},
+			requested: []string{"items.0.label", "fixed.1"},
+			want:      []string{"fixed.1.label", "items.0.label"},
+		},
+		{
+			name:      "indexed and implicit elements",
+			allowed:   []string{"items.1"},
+			requested: []string{"items.label", "items.count"},
+			want:      []string{"items.1.count", "items.1.label"},
+		},
 		{
 			name:      "custom marshaler is a leaf",
 			allowed:   []string{"custom", "custom.nested"},
@@ -446,6 +470,93 @@ func TestMizu_FieldMaskOverwriteMissingMapKey(t *testing.T) {
 	})
 }
 
+func TestMizu_FieldMaskIntersectWildcards(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []string
+		requested []string
+		want      []string
+	}{
+		{
+			name:      "map keys and elements",
+			allowed:   []string{"attributes.*.city", "items.*.label", "fixed.1", "tags.0"},
+			requested: []string{"attributes.home", "items.1", "fixed", "tags.*"},
+			want:      []string{"attributes.home.city", "fixed.1", "items.1.label", "tags.0"},
+		},
+		{
+			name:      "struct wildcard narrows to valid fields",
+			allowed:   []string{"*.city"},
+			requested: []string{"address", "displayName", "attributes.work"},
+			want:      []string{"address.city"},
+		},
+		{
+			name:      "invalid elements",
+			allowed:   []string{"fixed", "items", "tags", "custom"},
+			requested: []string{"fixed.2", "items.-1", "items.+1", "tags.0.value", "custom.*"},
+			want:      []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mask := mizu.Intersect[fieldMaskProfile](tc.allowed, tc.requested)
+			assert.Equal(t, tc.want, mask.Paths())
+		})
+	}
+}
+
+func TestMizu_FieldMaskWildcards(t *testing.T) {
+	newMask := func(paths ...string) *mizu.FieldMask[fieldMaskProfile] {
+		return mizu.Intersect[fieldMaskProfile](paths, paths)
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		profile := newFieldMaskProfile()
+		require.NoError(t, newMask("items.0.label", "attributes.*.zip", "pointerAttributes.*", "fixed.*.count").Filter(&profile))
+		assert.Equal(t, []fieldMaskItem{{Label: "first"}, {}}, profile.Items)
+		assert.Equal(t, map[string]fieldMaskAddress{"home": {Zip: "310000"}, "work": {Zip: "100000"}}, profile.Attributes)
+		assert.Equal(t, newFieldMaskProfile().PointerAttributes, profile.PointerAttributes)
+		assert.Equal(t, [2]fieldMaskItem{{Count: 3}, {Count: 4}}, profile.Fixed)
+		assert.Empty(t, profile.DisplayName)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		profile := newFieldMaskProfile()
+		require.NoError(t, newMask("items.1", "attributes.*.city", "tags.0", "*.zip").Prune(&profile))
+		assert.Equal(t, []fieldMaskItem{{Label: "first", Count: 1}, {}}, profile.Items)
+		assert.Equal(t, map[string]fieldMaskAddress{"home": {Zip: "310000"}, "work": {Zip: "100000"}}, profile.Attributes)
+		assert.Equal(t, []string{"", "two"}, profile.Tags)
+		assert.Equal(t, fieldMaskAddress{City: "Shanghai"}, *profile.Address)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		source := newFieldMaskProfile()
+		source.Items = []fieldMaskItem{{Label: "source", Count: 10}}
+		source.Attributes = map[string]fieldMaskAddress{"home": {City: "Chengdu", Zip: "610000"}, "new": {City: "Xian"}}
+		source.PointerAttributes = map[string]*fieldMaskAddress{"work": {City: "Guangzhou"}}
+
+		target := newFieldMaskProfile()
+		require.NoError(t, newMask("items.0.count", "items.1.count", "attributes.*.city", "pointerAttributes.*").Overwrite(&source, &target))
+		assert.Equal(t, []fieldMaskItem{{Label: "first", Count: 10}, {Label: "second", Count: 2}}, target.Items,
+			"single indexes keep the destination length")
+		assert.Equal(t, map[string]fieldMaskAddress{
+			"home": {City: "Chengdu", Zip: "310000"},
+			"work": {Zip: "100000"},
+			"new":  {City: "Xian"},
+		}, target.Attributes)
+		assert.Equal(t, source.PointerAttributes, target.PointerAttributes)
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		before, after := newFieldMaskProfile(), newFieldMaskProfile()
+		after.Items[1].Label = "renamed"
+		assert.Equal(t, []mizu.FieldMaskChange{
+			{Path: "attributes.*.city", Before: map[string]any{"home": "Hangzhou", "work": "Beijing"}, After: map[string]any{"home": "Hangzhou", "work": "Beijing"}},
+			{Path: "items.1.label", Before: "second", After: "renamed"},
+		}, newMask("items.1.label", "attributes.*.city").Changes(&before, &after))
+	})
+}
+
 func TestMizu_FieldMaskErrors(t *testing.T) {
 	t.Run("unsupported type", func(t *testing.T) {
 		value := 7
@@ -2,9 +2,11 @@ package mizu
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
@@ -13,10 +15,15 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/mail"
+	"net/textproto"
 	"reflect"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var ErrFileTooLarge = errors.New("file too large")
@@ -33,6 +40,10 @@ type FileReader struct {
 	closer      io.Closer
 	sniffSize   int
 	mimeSniffer [512]byte
+
+	ctx      context.Context
+	progress *fileProgress
+	throttle *fileThrottle
 }
 
 // FileReaderOption configures a FileReader.
@@ -46,12 +57,52 @@ func WithFileLimitBytes(limit int64) FileReaderOption {
 	}
 }
 
+// WithFileContext makes reads fail with the context error once ctx is
+// done, including reads waiting on WithFileRateLimit. Pass the request
+// context to stop consuming an upload the client abandoned.
+func WithFileContext(ctx context.Context) FileReaderOption {
+	return func(r *FileReader) {
+		r.ctx = ctx
+	}
+}
+
+// WithFileProgress calls callback with the number of bytes read so far
+// whenever at least everyBytes were read or interval elapsed since the
+// previous call, and once more at EOF for the bytes not reported yet.
+// With both granularities zero, callback is called after every read. The
+// callback runs on the reading goroutine and should return quickly.
+func WithFileProgress(callback func(readBytes int64), everyBytes int64, interval time.Duration) FileReaderOption {
+	return func(r *FileReader) {
+		if callback == nil {
+			r.progress = nil
+			return
+		}
+		r.progress = &fileProgress{callback: callback, everyBytes: everyBytes, interval: interval}
+	}
+}
+
+// WithFileRateLimit caps the read rate to bytesPerSecond with a token
+// bucket holding up to burstBytes, which also bounds the size of a single
+// underlying read. A non-positive rate disables the limit, a
+// non-positive burst defaults to one second worth of bytes.
+func WithFileRateLimit(bytesPerSecond, burstBytes int64) FileReaderOption {
+	return func(r *FileReader) {
+		if bytesPerSecond <= 0 {
+			r.throttle = nil
+			return
+		}
+		if burstBytes <= 0 {
+			burstBytes = bytesPerSecond
+		}
+		r.throttle = &fileThrottle{rate: float64(bytesPerSecond), burst: burstBytes}
+	}
+}
+
 // NewFileReader creates a streaming file reader that calculates a SHA-256
 // checksum and detects the MIME type from the first 512 bytes.
 func NewFileReader(rx io.ReadCloser, opts ...FileReaderOption) *FileReader {
 	hash := sha256.New()
 	reader := &FileReader{
-		inner:  io.TeeReader(rx, hash),
 		hash:   hash,
 		closer: rx,
 	}
@@ -63,7 +114,22 @@ func NewFileReader(rx io.ReadCloser, opts ...FileReaderOption) *FileReader {
 	if reader.limitBytes <= 0 {
 		reader.limitBytes = math.MaxInt64
 	}
+	if reader.ctx == nil {
+		reader.ctx = context.Background()
+	}
+
+	var source io.Reader = rx
+	if reader.throttle != nil {
+		reader.throttle.tokens = float64(reader.throttle.burst)
+		reader.throttle.last = time.Now()
+		source = &throttledReader{ctx: reader.ctx, inner: rx, throttle: reader.throttle}
+	}
+	reader.inner = io.TeeReader(source, hash)
 
+	// Leave a cancelled file untouched, its first Read reports the error.
+	if reader.ctx.Err() != nil {
+		return reader
+	}
 	n, _ := reader.inner.Read(reader.mimeSniffer[:])
 	if reader.sniffSize = n; n > 0 {
 		reader.inner = io.MultiReader(bytes.NewReader(reader.mimeSniffer[:n]), reader.inner)
@@ -83,6 +149,9 @@ func (r *FileReader) Read(p []byte) (int, error) {
 	if r.large {
 		return 0, fmt.Errorf("%w: %d > %d", ErrFileTooLarge, r.readBytes, r.limitBytes)
 	}
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
 
 	nbyte, err := r.inner.Read(p)
 	r.readBytes += int64(nbyte)
@@ -91,6 +160,9 @@ func (r *FileReader) Read(p []byte) (int, error) {
 		r.large = true
 		return nbyte, fmt.Errorf("%w: %d > %d", ErrFileTooLarge, r.readBytes, r.limitBytes)
 	}
+	if r.progress != nil {
+		r.progress.observe(r.readBytes, errors.Is(err, io.EOF))
+	}
 	return nbyte, err
 }
 
@@ -114,6 +186,89 @@ func (r *FileReader) Close() error {
 	return r.closer.Close()
 }
 
+type fileProgress struct {
+	callback   func(readBytes int64)
+	everyBytes int64
+	interval   time.Duration
+
+	reported     int64
+	reportedAt   time.Time
+	reportedDone bool
+}
+
+func (p *fileProgress) observe(readBytes int64, done bool) {
+	if p.reportedDone {
+		return
+	}
+	now := time.Now()
+	if p.reportedAt.IsZero() {
+		p.reportedAt = now
+	}
+
+	// The final call is skipped when the last read already reported the
+	// whole file.
+	report := done && readBytes > p.reported
+	switch {
+	case p.everyBytes <= 0 && p.interval <= 0:
+		report = report || readBytes > p.reported
+	case p.everyBytes > 0 && readBytes-p.reported >= p.everyBytes:
+		report = true
+	case p.interval > 0 && now.Sub(p.reportedAt) >= p.interval:
+		report = true
+	}
+	if !report {
+		return
+	}
+	p.reported, p.reportedAt, p.reportedDone = readBytes, now, done
+	p.callback(readBytes)
+}
+
+// fileThrottle is a token bucket refilled at rate bytes per second. Reads
+// spend tokens after the fact, so the bucket may go negative and the
+// reader then waits until it is paid back.
+type fileThrottle struct {
+	rate   float64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+func (t *fileThrottle) wait(ctx context.Context, nbyte int) error {
+	now := time.Now()
+	t.tokens = min(float64(t.burst), t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	t.tokens -= float64(nbyte)
+	if t.tokens >= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(-t.tokens / t.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	inner    io.Reader
+	throttle *fileThrottle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.throttle.burst {
+		p = p[:r.throttle.burst]
+	}
+	nbyte, err := r.inner.Read(p)
+	if waitErr := r.throttle.wait(r.ctx, nbyte); waitErr != nil {
+		return nbyte, waitErr
+	}
+	return nbyte, err
+}
+
 // FormReader streams multipart form parts and locates a configured file part.
 type FormReader interface {
 	// NextPart returns the next multipart form part.
@@ -138,6 +293,31 @@ func WithFormFieldLimitBytes(limit int64) FormReaderOption {
 	}
 }
 
+// FormError describes a declared form field that failed one of its
+// constraints.
+type FormError struct {
+	Field      string `json:"field"`
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+}
+
+func (e FormError) Error() string {
+	return e.Message
+}
+
+// FormErrors aggregates every constraint failure of a multipart form. It
+// is returned when the stream reaches EOF, so a handler can report all
+// failures in a single response.
+type FormErrors []FormError
+
+func (e FormErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, item := range e {
+		messages[i] = item.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
 type formReader struct {
 	fileField       string
 	fieldLimitBytes int64
@@ -146,6 +326,7 @@ type formReader struct {
 	message         reflect.Value
 	fields          map[string]*formField
 	fieldOrder      []string
+	fieldErrors     FormErrors
 	fileSeen        bool
 	closed          bool
 	complete        bool
@@ -157,12 +338,44 @@ type formField struct {
 	name     string
 	typ      reflect.Type
 	required bool
+	rules    formRules
 	seen     int
 }
 
+// formRules holds the constraints declared with min, max, len, pattern,
+// enum and email tags. min, max and len bound numeric values, the rune
+// count of strings, the byte count of []byte, or the number of values of
+// a repeated field. pattern, enum and email apply to the raw text of each
+// value.
+type formRules struct {
+	measure  formMeasure
+	minimum  *float64
+	maximum  *float64
+	length   *float64
+	pattern  *regexp.Regexp
+	enum     []string
+	email    bool
+	declared bool
+}
+
+type formMeasure int
+
+const (
+	_FORM_MEASURE_NONE formMeasure = iota
+	_FORM_MEASURE_VALUE
+	_FORM_MEASURE_RUNES
+	_FORM_MEASURE_BYTES
+	_FORM_MEASURE_COUNT
+)
+
 // NewFormReader creates a typed multipart/form-data reader for an HTTP
 // request. message must be a non-nil pointer to a struct. NextPart consumes
 // and decodes declared non-file fields while leaving unknown parts untouched.
+//
+// Fields may declare required, min, max, len, pattern, enum (comma
+// separated) and email tags. Constraints are checked as each part is
+// decoded and when the stream reaches EOF; failures, including values that
+// do not decode into their field type, are reported together as FormErrors.
 func NewFormReader[T any](
 	fileField string, request *http.Request, message *T, opts ...FormReaderOption,
 ) (FormReader, error) {
@@ -233,8 +446,12 @@ func NewFormReader[T any](
 				return nil, fmt.Errorf("parse required tag on %s: %w", field.Name, err)
 			}
 		}
+		rules, err := newFormRules(field)
+		if err != nil {
+			return nil, err
+		}
 		reader.fields[name] = &formField{
-			index: index, name: field.Name, typ: field.Type, required: required,
+			index: index, name: field.Name, typ: field.Type, required: required, rules: rules,
 		}
 		reader.fieldOrder = append(reader.fieldOrder, name)
 	}
@@ -242,6 +459,172 @@ func NewFormReader[T any](
 	return reader, nil
 }
 
+func newFormRules(field reflect.StructField) (formRules, error) {
+	rules := formRules{}
+	typ := field.Type
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch {
+	case isRepeatedFormField(field.Type):
+		rules.measure = _FORM_MEASURE_COUNT
+	case typ.Kind() == reflect.String:
+		rules.measure = _FORM_MEASURE_RUNES
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		rules.measure = _FORM_MEASURE_BYTES
+	case reflect.Int <= typ.Kind() && typ.Kind() <= reflect.Float64:
+		rules.measure = _FORM_MEASURE_VALUE
+	}
+
+	bounds := []struct {
+		tag    string
+		target **float64
+	}{
+		{"min", &rules.minimum},
+		{"max", &rules.maximum},
+		{"len", &rules.length},
+	}
+	for _, bound := range bounds {
+		raw, ok := field.Tag.Lookup(bound.tag)
+		if !ok {
+			continue
+		}
+		if rules.measure == _FORM_MEASURE_NONE || bound.tag == "len" && rules.measure == _FORM_MEASURE_VALUE {
+			return rules, fmt.Errorf("%s tag on %s is not supported for %s", bound.tag, field.Name, field.Type)
+		}
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return rules, fmt.Errorf("parse %s tag on %s: %w", bound.tag, field.Name, err)
+		}
+		if rules.measure != _FORM_MEASURE_VALUE && (parsed < 0 || parsed != math.Trunc(parsed)) {
+			return rules, fmt.Errorf("parse %s tag on %s: length must be a non-negative integer", bound.tag, field.Name)
+		}
+		*bound.target = &parsed
+		rules.declared = true
+	}
+	if rules.minimum != nil && rules.maximum != nil && *rules.minimum > *rules.maximum {
+		return rules, fmt.Errorf("min tag on %s exceeds max tag", field.Name)
+	}
+
+	if raw, ok := field.Tag.Lookup("pattern"); ok {
+		pattern, err := regexp.Compile(raw)
+		if err != nil {
+			return rules, fmt.Errorf("parse pattern tag on %s: %w", field.Name, err)
+		}
+		rules.pattern = pattern
+		rules.declared = true
+	}
+	if raw, ok := field.Tag.Lookup("enum"); ok {
+		rules.enum = strings.Split(raw, ",")
+		rules.declared = true
+	}
+	if raw, ok := field.Tag.Lookup("email"); ok {
+		email, err := strconv.ParseBool(raw)
+		if err != nil {
+			return rules, fmt.Errorf("parse email tag on %s: %w", field.Name, err)
+		}
+		rules.email = email
+		rules.declared = rules.declared || email
+	}
+	return rules, nil
+}
+
+// checkValue validates a single decoded part. raw is the part content and
+// value is the decoded struct field.
+func (r formRules) checkValue(name string, raw []byte, value reflect.Value) FormErrors {
+	if !r.declared {
+		return nil
+	}
+	var errs FormErrors
+	if r.measure != _FORM_MEASURE_COUNT {
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		var measured float64
+		switch r.measure {
+		case _FORM_MEASURE_VALUE:
+			switch {
+			case value.CanInt():
+				measured = float64(value.Int())
+			case value.CanUint():
+				measured = float64(value.Uint())
+			case value.CanFloat():
+				measured = value.Float()
+			}
+		case _FORM_MEASURE_RUNES:
+			measured = float64(utf8.RuneCountInString(value.String()))
+		case _FORM_MEASURE_BYTES:
+			measured = float64(value.Len())
+		}
+		errs = append(errs, r.checkBounds(name, measured)...)
+	}
+
+	text := string(raw)
+	if r.pattern != nil && !r.pattern.MatchString(text) {
+		errs = append(errs, FormError{
+			Field: name, Constraint: "pattern",
+			Message: fmt.Sprintf("form field %q must match pattern %q", name, r.pattern.String()),
+		})
+	}
+	if r.enum != nil && !slices.Contains(r.enum, text) {
+		errs = append(errs, FormError{
+			Field: name, Constraint: "enum",
+			Message: fmt.Sprintf("form field %q must be one of %s", name, strings.Join(r.enum, ", ")),
+		})
+	}
+	if r.email {
+		address, err := mail.ParseAddress(text)
+		if err != nil || address.Name != "" || address.Address != text {
+			errs = append(errs, FormError{
+				Field: name, Constraint: "email",
+				Message: fmt.Sprintf("form field %q must be a valid email address", name),
+			})
+		}
+	}
+	return errs
+}
+
+func (r formRules) checkBounds(name string, measured float64) FormErrors {
+	unit := ""
+	switch r.measure {
+	case _FORM_MEASURE_RUNES:
+		unit = " character"
+	case _FORM_MEASURE_BYTES:
+		unit = " byte"
+	case _FORM_MEASURE_COUNT:
+		unit = " value"
+	}
+	format := func(bound float64) string {
+		if unit != "" && bound != 1 {
+			return strconv.FormatFloat(bound, 'f', -1, 64) + unit + "s"
+		}
+		return strconv.FormatFloat(bound, 'f', -1, 64) + unit
+	}
+
+	var errs FormErrors
+	if r.length != nil && measured != *r.length {
+		errs = append(errs, FormError{
+			Field: name, Constraint: "len",
+			Message: fmt.Sprintf("form field %q must have exactly %s", name, format(*r.length)),
+		})
+	}
+	if r.minimum != nil && measured < *r.minimum {
+		message := fmt.Sprintf("form field %q must be at least %s", name, format(*r.minimum))
+		if unit != "" {
+			message = fmt.Sprintf("form field %q must have at least %s", name, format(*r.minimum))
+		}
+		errs = append(errs, FormError{Field: name, Constraint: "min", Message: message})
+	}
+	if r.maximum != nil && measured > *r.maximum {
+		message := fmt.Sprintf("form field %q must be at most %s", name, format(*r.maximum))
+		if unit != "" {
+			message = fmt.Sprintf("form field %q must have at most %s", name, format(*r.maximum))
+		}
+		errs = append(errs, FormError{Field: name, Constraint: "max", Message: message})
+	}
+	return errs
+}
+
 func validateFormBoundary(boundary string) error {
 	if len(boundary) < 1 || len(boundary) > 70 {
 		return errors.New("invalid boundary length")
@@ -334,10 +717,16 @@ func (r *formReader) NextPart() (*multipart.Part, error) {
 	if int64(len(raw)) > r.fieldLimitBytes {
 		return nil, fmt.Errorf("form field %q exceeds %d bytes", name, r.fieldLimitBytes)
 	}
-	if err := decodeFormField(r.message.Field(field.index), raw); err != nil {
-		return nil, fmt.Errorf("decode form field %q: %w", name, err)
-	}
 	field.seen++
+	target := r.message.Field(field.index)
+	if err := decodeFormField(target, raw); err != nil {
+		r.fieldErrors = append(r.fieldErrors, FormError{
+			Field: name, Constraint: "type",
+			Message: fmt.Sprintf("decode form field %q: %v", name, err),
+		})
+		return part, nil
+	}
+	r.fieldErrors = append(r.fieldErrors, field.rules.checkValue(name, raw, target)...)
 	return part, nil
 }
 
@@ -387,14 +776,24 @@ func (r *formReader) completeFields() error {
 		return r.completeErr
 	}
 	r.complete = true
+	errs := r.fieldErrors
 	for _, name := range r.fieldOrder {
 		field := r.fields[name]
 		if field.required && field.seen == 0 {
-			r.completeErr = fmt.Errorf("required form field %q is missing", name)
-			return r.completeErr
+			errs = append(errs, FormError{
+				Field: name, Constraint: "required",
+				Message: fmt.Sprintf("required form field %q is missing", name),
+			})
+			continue
+		}
+		if field.rules.measure == _FORM_MEASURE_COUNT {
+			errs = append(errs, field.rules.checkBounds(name, float64(field.seen))...)
 		}
 	}
-	return nil
+	if len(errs) > 0 {
+		r.completeErr = errs
+	}
+	return r.completeErr
 }
 
 func isRepeatedFormField(typ reflect.Type) bool {
@@ -490,3 +889,285 @@ func parseFormValue(typ reflect.Type, raw []byte) (reflect.Value, error) {
 	}
 	return value, nil
 }
+
+// FormWriter streams a multipart response. Typed fields are encoded with
+// the same `form` tag rules as NewFormReader, so a message written by
+// FormWriter decodes back through NewFormReader unchanged.
+type FormWriter struct {
+	inner       *multipart.Writer
+	rc          *http.ResponseController
+	disposition string
+}
+
+// FormWriterOption configures a FormWriter.
+type FormWriterOption func(*formWriterConfig)
+
+type formWriterConfig struct {
+	mediaType string
+	boundary  string
+}
+
+// WithFormMediaType sets the multipart media type of the response, e.g.
+// "multipart/mixed". The default is "multipart/form-data".
+func WithFormMediaType(mediaType string) FormWriterOption {
+	return func(c *formWriterConfig) {
+		c.mediaType = mediaType
+	}
+}
+
+// WithFormBoundary sets the multipart boundary instead of a random one.
+func WithFormBoundary(boundary string) FormWriterOption {
+	return func(c *formWriterConfig) {
+		c.boundary = boundary
+	}
+}
+
+// NewFormWriter creates a FormWriter over w and sets the Content-Type
+// header, including the boundary, so it must be called before the
+// response header is written. Close must be called to terminate the
+// multipart body.
+func NewFormWriter(w http.ResponseWriter, opts ...FormWriterOption) (*FormWriter, error) {
+	if w == nil {
+		return nil, errors.New("form response writer is nil")
+	}
+	config := &formWriterConfig{mediaType: "multipart/form-data"}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	mediaType := strings.ToLower(config.mediaType)
+	if !strings.HasPrefix(mediaType, "multipart/") || mediaType == "multipart/" {
+		return nil, fmt.Errorf("expected multipart media type, got %q", config.mediaType)
+	}
+	inner := multipart.NewWriter(w)
+	if config.boundary != "" {
+		if err := validateFormBoundary(config.boundary); err != nil {
+			return nil, fmt.Errorf("invalid form boundary: %w", err)
+		}
+		if err := inner.SetBoundary(config.boundary); err != nil {
+			return nil, fmt.Errorf("invalid form boundary: %w", err)
+		}
+	}
+	contentType := mime.FormatMediaType(mediaType, map[string]string{"boundary": inner.Boundary()})
+	if contentType == "" {
+		return nil, fmt.Errorf("invalid form media type %q", config.mediaType)
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	// Parts of a multipart/form-data body are named by a form-data
+	// disposition, other multipart types carry the name on an inline one.
+	disposition := "inline"
+	if mediaType == "multipart/form-data" {
+		disposition = "form-data"
+	}
+	return &FormWriter{inner: inner, rc: http.NewResponseController(w), disposition: disposition}, nil
+}
+
+// Boundary returns the multipart boundary of the response.
+func (w *FormWriter) Boundary() string {
+	return w.inner.Boundary()
+}
+
+// WriteFields writes the exported fields of message, a struct or a
+// pointer to one, in declaration order. Slices are written as repeated
+// parts and nil pointers are omitted.
+func (w *FormWriter) WriteFields(message any) error {
+	value := reflect.ValueOf(message)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return errors.New("form message is nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("form message must be a struct, got %T", message)
+	}
+
+	seen := make(map[string]string)
+	for index := range value.NumField() {
+		field := value.Type().Field(index)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, ignored := formFieldName(field)
+		if ignored {
+			continue
+		}
+		if previous, ok := seen[name]; ok {
+			return fmt.Errorf("duplicate form field name %q on %s and %s", name, previous, field.Name)
+		}
+		seen[name] = field.Name
+
+		values, err := formatFormField(value.Field(index))
+		if err != nil {
+			return fmt.Errorf("encode form field %q: %w", name, err)
+		}
+		for _, raw := range values {
+			if err := w.WriteField(name, raw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteField writes a single text part named name.
+func (w *FormWriter) WriteField(name string, value []byte) error {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	part, err := w.CreateField(name, header)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(value); err != nil {
+		return fmt.Errorf("write form field %q: %w", name, err)
+	}
+	return nil
+}
+
+// WriteJSON writes value as an application/json part named name, which
+// suits metadata that precedes the binary parts of a response.
+func (w *FormWriter) WriteJSON(name string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode form field %q: %w", name, err)
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "application/json")
+	part, err := w.CreateField(name, header)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(raw); err != nil {
+		return fmt.Errorf("write form field %q: %w", name, err)
+	}
+	return nil
+}
+
+// CreateField starts a part named name with the extra headers in header
+// and returns a writer for its body. The part ends when the next part is
+// created or the FormWriter is closed.
+func (w *FormWriter) CreateField(name string, header textproto.MIMEHeader) (io.Writer, error) {
+	return w.createPart(map[string]string{"name": name}, header)
+}
+
+// CreateFile starts a file part named name. The filename is encoded per
+// RFC 2231 when it is not plain ASCII, and Content-Type defaults to
+// application/octet-stream when header does not set it.
+func (w *FormWriter) CreateFile(name, filename string, header textproto.MIMEHeader) (io.Writer, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("form file %q has no filename", name)
+	}
+	params := map[string]string{"name": name, "filename": filename}
+	if header.Get("Content-Type") == "" {
+		header = cloneMIMEHeader(header)
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	return w.createPart(params, header)
+}
+
+// CreatePart starts a part with exactly the headers in header, for
+// multipart bodies whose parts are not named, e.g. batch responses.
+func (w *FormWriter) CreatePart(header textproto.MIMEHeader) (io.Writer, error) {
+	part, err := w.inner.CreatePart(header)
+	if err != nil {
+		return nil, fmt.Errorf("create form part: %w", err)
+	}
+	return part, nil
+}
+
+// Flush sends the parts written so far to the client, if the underlying
+// http.ResponseWriter supports flushing.
+func (w *FormWriter) Flush() error {
+	if err := w.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// Close writes the closing boundary. It does not close the response.
+func (w *FormWriter) Close() error {
+	return w.inner.Close()
+}
+
+func (w *FormWriter) createPart(params map[string]string, header textproto.MIMEHeader) (io.Writer, error) {
+	if params["name"] == "" {
+		return nil, errors.New("form part name is required")
+	}
+	disposition := mime.FormatMediaType(w.disposition, params)
+	if disposition == "" {
+		return nil, fmt.Errorf("invalid form part name %q", params["name"])
+	}
+	header = cloneMIMEHeader(header)
+	header.Set("Content-Disposition", disposition)
+	return w.CreatePart(header)
+}
+
+func cloneMIMEHeader(header textproto.MIMEHeader) textproto.MIMEHeader {
+	if header == nil {
+		return make(textproto.MIMEHeader)
+	}
+	return textproto.MIMEHeader(http.Header(header).Clone())
+}
+
+func formatFormField(value reflect.Value) ([][]byte, error) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([][]byte, 0, value.Len())
+		for index := range value.Len() {
+			raw, err := formatFormValue(value.Index(index))
+			if err != nil {
+				return nil, err
+			}
+			if raw != nil {
+				values = append(values, raw)
+			}
+		}
+		return values, nil
+	}
+	raw, err := formatFormValue(value)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return [][]byte{raw}, nil
+}
+
+func formatFormValue(value reflect.Value) ([]byte, error) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	marshaler := reflect.TypeFor[encoding.TextMarshaler]()
+	if value.Type().Implements(marshaler) {
+		return value.Interface().(encoding.TextMarshaler).MarshalText()
+	}
+	if value.CanAddr() && value.Addr().Type().Implements(marshaler) {
+		return value.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return []byte(value.String()), nil
+	case reflect.Bool:
+		return strconv.AppendBool(nil, value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(nil, value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(nil, value.Float(), 'g', -1, value.Type().Bits()), nil
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte{}, value.Bytes()...), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported form field type %s", value.Type())
+}
@@ -2,6 +2,7 @@ package mizu_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -9,8 +10,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/humbornjo/mizu"
 	"github.com/stretchr/testify/assert"
@@ -202,6 +205,21 @@ func TestMizu_NewFormReaderValidation(t *testing.T) {
 	type invalidRequiredForm struct {
 		Name string `required:"sometimes"`
 	}
+	type invalidMinForm struct {
+		Name string `min:"few"`
+	}
+	type invalidLenForm struct {
+		Count int `len:"2"`
+	}
+	type invalidBoundsForm struct {
+		Count int `min:"5" max:"1"`
+	}
+	type invalidPatternForm struct {
+		Name string `pattern:"["`
+	}
+	type unsupportedMinForm struct {
+		Enabled bool `min:"1"`
+	}
 
 	validRequest := func(t *testing.T) *http.Request {
 		request, _, _ := newMultipartRequest(t)
@@ -319,6 +337,46 @@ func TestMizu_NewFormReaderValidation(t *testing.T) {
 			},
 			want: "parse required tag",
 		},
+		{
+			name: "invalid min tag",
+			run: func(t *testing.T) error {
+				_, err := mizu.NewFormReader("file", validRequest(t), &invalidMinForm{})
+				return err
+			},
+			want: "parse min tag on Name",
+		},
+		{
+			name: "len tag on number",
+			run: func(t *testing.T) error {
+				_, err := mizu.NewFormReader("file", validRequest(t), &invalidLenForm{})
+				return err
+			},
+			want: "len tag on Count is not supported",
+		},
+		{
+			name: "min exceeds max",
+			run: func(t *testing.T) error {
+				_, err := mizu.NewFormReader("file", validRequest(t), &invalidBoundsForm{})
+				return err
+			},
+			want: "min tag on Count exceeds max tag",
+		},
+		{
+			name: "invalid pattern tag",
+			run: func(t *testing.T) error {
+				_, err := mizu.NewFormReader("file", validRequest(t), &invalidPatternForm{})
+				return err
+			},
+			want: "parse pattern tag on Name",
+		},
+		{
+			name: "min tag on bool",
+			run: func(t *testing.T) error {
+				_, err := mizu.NewFormReader("file", validRequest(t), &unsupportedMinForm{})
+				return err
+			},
+			want: "min tag on Enabled is not supported",
+		},
 		{
 			name: "invalid field limit",
 			run: func(t *testing.T) error {
@@ -352,8 +410,16 @@ func TestMizu_FormReaderErrors(t *testing.T) {
 		form, err := mizu.NewFormReader("file", request, &fields{})
 		require.NoError(t, err)
 		defer form.Close()
-		_, _, err = form.File()
-		assert.ErrorContains(t, err, `decode form field "count"`)
+		file, purge, err := form.File()
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, file)
+		require.NoError(t, err)
+		var formErrs mizu.FormErrors
+		require.ErrorAs(t, purge(), &formErrs)
+		require.Len(t, formErrs, 1)
+		assert.Equal(t, "count", formErrs[0].Field)
+		assert.Equal(t, "type", formErrs[0].Constraint)
+		assert.Contains(t, formErrs[0].Message, `decode form field "count"`)
 	})
 
 	t.Run("duplicate singleton", func(t *testing.T) {
@@ -433,6 +499,99 @@ func TestMizu_FormReaderErrors(t *testing.T) {
 	})
 }
 
+func TestMizu_FormReaderConstraints(t *testing.T) {
+	type fields struct {
+		Name   string   `form:"name" min:"2" max:"5"`
+		Code   string   `form:"code" len:"3" pattern:"^[A-Z]+$"`
+		Age    *int     `form:"age" min:"18" max:"130"`
+		Ratio  float64  `form:"ratio" max:"1"`
+		Status string   `form:"status" enum:"draft,published"`
+		Email  string   `form:"email" email:"true"`
+		Raw    []byte   `form:"raw" max:"2"`
+		Labels []string `form:"label" min:"1" max:"2" enum:"a,b"`
+		Owner  string   `form:"owner" required:"true"`
+	}
+
+	testCases := []struct {
+		name  string
+		parts []formPart
+		want  mizu.FormErrors
+	}{
+		{
+			name: "valid",
+			parts: []formPart{
+				{name: "name", data: []byte("名字")},
+				{name: "code", data: []byte("ABC")},
+				{name: "age", data: []byte("18")},
+				{name: "ratio", data: []byte("0.5")},
+				{name: "status", data: []byte("draft")},
+				{name: "email", data: []byte("mizu@example.com")},
+				{name: "raw", data: []byte("ok")},
+				{name: "label", data: []byte("a")},
+				{name: "owner", data: []byte("me")},
+			},
+		},
+		{
+			name: "aggregated",
+			parts: []formPart{
+				{name: "name", data: []byte("x")},
+				{name: "code", data: []byte("abcd")},
+				{name: "age", data: []byte("17")},
+				{name: "ratio", data: []byte("1.5")},
+				{name: "status", data: []byte("archived")},
+				{name: "email", data: []byte("Mizu <mizu@example.com>")},
+				{name: "raw", data: []byte("big")},
+				{name: "label", data: []byte("a")},
+				{name: "label", data: []byte("b")},
+				{name: "label", data: []byte("c")},
+			},
+			want: mizu.FormErrors{
+				{Field: "name", Constraint: "min", Message: `form field "name" must have at least 2 characters`},
+				{Field: "code", Constraint: "len", Message: `form field "code" must have exactly 3 characters`},
+				{Field: "code", Constraint: "pattern", Message: `form field "code" must match pattern "^[A-Z]+$"`},
+				{Field: "age", Constraint: "min", Message: `form field "age" must be at least 18`},
+				{Field: "ratio", Constraint: "max", Message: `form field "ratio" must be at most 1`},
+				{Field: "status", Constraint: "enum", Message: `form field "status" must be one of draft, published`},
+				{Field: "email", Constraint: "email", Message: `form field "email" must be a valid email address`},
+				{Field: "raw", Constraint: "max", Message: `form field "raw" must have at most 2 bytes`},
+				{Field: "label", Constraint: "enum", Message: `form field "label" must be one of a, b`},
+				{Field: "label", Constraint: "max", Message: `form field "label" must have at most 2 values`},
+				{Field: "owner", Constraint: "required", Message: `required form field "owner" is missing`},
+			},
+		},
+		{
+			name:  "missing repeated field",
+			parts: []formPart{{name: "owner", data: []byte("me")}},
+			want: mizu.FormErrors{
+				{Field: "label", Constraint: "min", Message: `form field "label" must have at least 1 value`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := append(tc.parts, formPart{name: "file", filename: "file.txt", data: []byte("data")})
+			request, _, _ := newMultipartRequest(t, parts...)
+			form, err := mizu.NewFormReader("file", request, &fields{})
+			require.NoError(t, err)
+			defer form.Close()
+
+			file, purge, err := form.File()
+			require.NoError(t, err, "constraint failures are reported at EOF")
+			_, err = io.Copy(io.Discard, file)
+			require.NoError(t, err)
+			err = purge()
+			if tc.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			var formErrs mizu.FormErrors
+			require.ErrorAs(t, err, &formErrs)
+			assert.Equal(t, tc.want, formErrs)
+		})
+	}
+}
+
 func TestMizu_FormReaderClose(t *testing.T) {
 	request, body, _ := newMultipartRequest(t, formPart{name: "file", filename: "file.txt", data: []byte("data")})
 	form, err := mizu.NewFormReader("file", request, &struct{}{})
@@ -445,6 +604,126 @@ func TestMizu_FormReaderClose(t *testing.T) {
 	assert.ErrorContains(t, err, "form reader is closed")
 }
 
+func TestMizu_FormWriter(t *testing.T) {
+	pointer := 7
+	code := upperText("abc")
+	message := typedUploadForm{
+		Title:    "release",
+		Enabled:  true,
+		Count:    -3,
+		Unsigned: 9,
+		Ratio:    0.5,
+		Data:     formBytes("raw"),
+		Pointer:  &pointer,
+		Code:     "xyz",
+		Labels:   []formAlias{"a", "b"},
+		Texts:    []*upperText{&code, nil},
+		Ignored:  "secret",
+		Trailing: "done",
+	}
+
+	recorder := httptest.NewRecorder()
+	writer, err := mizu.NewFormWriter(recorder)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteJSON("meta", map[string]int{"version": 2}))
+	require.NoError(t, writer.WriteFields(&message))
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "text/plain")
+	header.Set("X-Checksum", "sha256")
+	file, err := writer.CreateFile("file", "résumé.txt", header)
+	require.NoError(t, err)
+	_, err = file.Write([]byte("file content"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "multipart/form-data; boundary="+writer.Boundary(), recorder.Header().Get("Content-Type"))
+	assert.NotContains(t, recorder.Body.String(), "secret")
+
+	request := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(recorder.Body.Bytes()))
+	request.Header.Set("Content-Type", recorder.Header().Get("Content-Type"))
+	var decoded typedUploadForm
+	form, err := mizu.NewFormReader("file", request, &decoded)
+	require.NoError(t, err)
+	defer form.Close()
+
+	meta, err := form.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "meta", meta.FormName())
+	assert.Equal(t, "application/json", meta.Header.Get("Content-Type"))
+	raw, err := io.ReadAll(meta)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2}`, string(raw))
+
+	part, purge, err := form.File()
+	require.NoError(t, err)
+	assert.Equal(t, "résumé.txt", part.FileName())
+	assert.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+	assert.Equal(t, "sha256", part.Header.Get("X-Checksum"))
+	raw, err = io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "file content", string(raw))
+	require.NoError(t, purge())
+
+	upper := upperText("ABC")
+	message.Code = "XYZ"
+	message.Texts = []*upperText{&upper}
+	message.Ignored = ""
+	assert.Equal(t, message, decoded)
+}
+
+func TestMizu_FormWriterMixed(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writer, err := mizu.NewFormWriter(recorder,
+		mizu.WithFormMediaType("multipart/mixed"), mizu.WithFormBoundary("mizu-boundary"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed; boundary=mizu-boundary", recorder.Header().Get("Content-Type"))
+
+	require.NoError(t, writer.WriteFields(struct {
+		Name string `json:"name"`
+	}{Name: "mizu"}))
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Id", "<item-1>")
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write([]byte("batch"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader := multipart.NewReader(recorder.Body, "mizu-boundary")
+	named, err := reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, `inline; name=name`, named.Header.Get("Content-Disposition"))
+	raw, err := io.ReadAll(named)
+	require.NoError(t, err)
+	assert.Equal(t, "mizu", string(raw))
+	unnamed, err := reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "<item-1>", unnamed.Header.Get("Content-Id"))
+	_, err = reader.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMizu_FormWriterErrors(t *testing.T) {
+	_, err := mizu.NewFormWriter(httptest.NewRecorder(), mizu.WithFormMediaType("application/json"))
+	assert.ErrorContains(t, err, "expected multipart media type")
+	_, err = mizu.NewFormWriter(httptest.NewRecorder(), mizu.WithFormBoundary("bad\nboundary"))
+	assert.ErrorContains(t, err, "invalid form boundary")
+
+	writer, err := mizu.NewFormWriter(httptest.NewRecorder())
+	require.NoError(t, err)
+	assert.ErrorContains(t, writer.WriteFields("text"), "form message must be a struct")
+	assert.ErrorContains(t, writer.WriteFields(struct {
+		Nested struct{} `form:"nested"`
+	}{}), "unsupported form field type")
+	assert.ErrorContains(t, writer.WriteFields(struct {
+		A string `form:"same"`
+		B string `form:"same"`
+	}{}), `duplicate form field name "same"`)
+	_, err = writer.CreateFile("file", "", nil)
+	assert.ErrorContains(t, err, "has no filename")
+}
+
 func TestMizu_FileReader(t *testing.T) {
 	data := []byte("hello, mizu\n")
 	inner := &trackingReadCloser{Reader: bytes.NewReader(data)}
@@ -482,4 +761,73 @@ func TestMizu_FileReaderLimit(t *testing.T) {
 	assert.True(t, errors.Is(err, mizu.ErrFileTooLarge))
 }
 
+func TestMizu_FileReaderProgress(t *testing.T) {
+	testcases := []struct {
+		name       string
+		everyBytes int64
+		expected   []int64
+	}{
+		{name: "every read", expected: []int64{4, 8, 10}},
+		{name: "byte granularity", everyBytes: 5, expected: []int64{8, 10}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reports []int64
+			reader := mizu.NewFileReader(
+				io.NopCloser(bytes.NewReader([]byte("0123456789"))),
+				mizu.WithFileProgress(func(readBytes int64) {
+					reports = append(reports, readBytes)
+				}, tc.everyBytes, 0),
+			)
+			buffer := make([]byte, 4)
+			for {
+				_, err := reader.Read(buffer)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, reports)
+		})
+	}
+}
+
+func TestMizu_FileReaderRateLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("m"), 300)
+	start := time.Now()
+	reader := mizu.NewFileReader(
+		io.NopCloser(bytes.NewReader(data)),
+		mizu.WithFileRateLimit(2000, 100),
+	)
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data, actual)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "200 bytes past the burst take 100ms")
+}
+
+func TestMizu_FileReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := mizu.NewFileReader(
+		io.NopCloser(bytes.NewReader(bytes.Repeat([]byte("m"), 64))),
+		mizu.WithFileContext(ctx),
+		mizu.WithFileRateLimit(1, 16),
+	)
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := io.ReadAll(reader)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second, "cancellation must interrupt the rate limit wait")
+
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	source := bytes.NewReader([]byte("unread"))
+	reader = mizu.NewFileReader(io.NopCloser(source), mizu.WithFileContext(ctx))
+	assert.Equal(t, 6, source.Len(), "a cancelled file is not sniffed")
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 var _ io.ReadCloser = (*mizu.FileReader)(nil)
@@ -14,7 +14,10 @@ import (
 
 type ctxkey int
 
-const _CTXKEY ctxkey = iota
+const (
+	_CTXKEY ctxkey = iota
+	_CTXKEY_FIELD_MASK
+)
 
 const (
 	_READINESS_DRAIN_DELAY = 5 * time.Second
@@ -80,8 +83,9 @@ func NewServer(srvName string, opts ...Option) *Server {
 		initialized:    &atomic.Bool{},
 		isShuttingDown: &atomic.Bool{},
 
-		hookStartup: &[]func(*Server){},
-		hookHandler: &[]func(*Server){},
+		hookStartup:  &[]func(*Server){},
+		hookHandler:  &[]func(*Server){},
+		hookShutdown: &[]func(*Server){},
 	}
 	server.initialized.Store(false)
 	server.isShuttingDown.Store(false)
@@ -0,0 +1,206 @@
+package mizuconnect
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// CachePolicy is the Cache-Control of the Connect GET responses of a
+// procedure. The zero value lets only the browser of the caller store
+// responses, and makes it revalidate every use with the ETag.
+//
+// Responses are private unless Public is set, since shared caches such as
+// CDNs key them by URL only: a public response to an authenticated call
+// would be served to other callers, as RFC 9111 lets shared caches store
+// responses marked public even for requests carrying Authorization. Set
+// Public only for procedures whose response does not depend on the caller.
+type CachePolicy struct {
+	// MaxAge is how long a response may be reused without revalidation.
+	MaxAge time.Duration
+	// SharedMaxAge overrides MaxAge for shared caches when non-zero. It
+	// applies to public policies only.
+	SharedMaxAge time.Duration
+	// StaleWhileRevalidate is how long after MaxAge a stale response may
+	// be reused while the cache revalidates it in the background.
+	StaleWhileRevalidate time.Duration
+	// Public lets shared caches store responses.
+	Public bool
+}
+
+// String returns the Cache-Control header value of the policy.
+func (p CachePolicy) String() string {
+	directives := []string{"private"}
+	if p.Public {
+		directives[0] = "public"
+	}
+	if p.MaxAge <= 0 {
+		directives = append(directives, "no-cache")
+	} else {
+		directives = append(directives, "max-age="+seconds(p.MaxAge))
+	}
+	if p.SharedMaxAge > 0 && p.Public {
+		directives = append(directives, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+type cachePolicy struct {
+	pattern string
+	policy  CachePolicy
+}
+
+// WithCrpcConditionalGet enables HTTP conditional caching of Connect GET
+// requests to procedures declared `idempotency_level = NO_SIDE_EFFECTS`.
+// Successful responses carry an ETag computed from the serialised
+// response and a Cache-Control header, and requests whose If-None-Match
+// holds the ETag get an empty 304 Not Modified. policy applies to
+// procedures without a WithCrpcCachePolicy.
+func WithCrpcConditionalGet(policy CachePolicy) Option {
+	return func(m *config) {
+		m.enableConditionalGet = true
+		m.defaultCachePolicy = policy
+	}
+}
+
+// WithCrpcCachePolicy sets the policy of the procedures matching
+// pattern, a path.Match glob over full procedure names such as
+// `/acme.user.v1.UserService/Get*`. The first matching pattern wins. It
+// enables conditional GET like WithCrpcConditionalGet, with a zero
+// default policy unless one is given.
+//
+// Example:
+//
+//	mizuconnect.WithCrpcCachePolicy("/acme.user.v1.UserService/GetAvatar",
+//		mizuconnect.CachePolicy{MaxAge: time.Hour, SharedMaxAge: 24 * time.Hour, Public: true})
+func WithCrpcCachePolicy(pattern string, policy CachePolicy) Option {
+	return func(m *config) {
+		m.enableConditionalGet = true
+		m.cachePolicies = append(m.cachePolicies, cachePolicy{pattern: pattern, policy: policy})
+	}
+}
+
+// conditional wraps the handler of service, mounted on pattern, with
+// conditional GET for its methods declared free of side effects.
+func (c *config) conditional(pattern string, sd protoreflect.ServiceDescriptor, handler http.Handler) http.Handler {
+	if !c.enableConditionalGet {
+		return handler
+	}
+
+	policies := make(map[string]string)
+	methods := sd.Methods()
+	for i := range methods.Len() {
+		method := methods.Get(i)
+		options, ok := method.Options().(*descriptorpb.MethodOptions)
+		if !ok || options.GetIdempotencyLevel() != descriptorpb.MethodOptions_NO_SIDE_EFFECTS {
+			continue
+		}
+		procedure := pattern + string(method.Name())
+		policy := c.defaultCachePolicy
+		for _, p := range c.cachePolicies {
+			if ok, _ := path.Match(p.pattern, procedure); ok {
+				policy = p.policy
+				break
+			}
+		}
+		policies[string(method.Name())] = policy.String()
+	}
+	if len(policies) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, name, _ := strings.Cut(r.URL.Path, pattern)
+		name, _, _ = strings.Cut(name, "/")
+		cacheControl, ok := policies[name]
+		if r.Method != http.MethodGet || !ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		buffer := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+		handler.ServeHTTP(buffer, r)
+		if buffer.status != http.StatusOK {
+			w.WriteHeader(buffer.status)
+			_, _ = w.Write(buffer.body.Bytes())
+			return
+		}
+
+		// The ETag covers the encoding of the body, so that differently
+		// compressed representations do not share it.
+		hash := sha256.New()
+		hash.Write([]byte(w.Header().Get("Content-Encoding"))) // nolint: errcheck
+		hash.Write([]byte{0})                                  // nolint: errcheck
+		hash.Write(buffer.body.Bytes())                        // nolint: errcheck
+		etag := `"` + base64.RawURLEncoding.EncodeToString(hash.Sum(nil)[:18]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if matchEtag(r.Header.Values("If-None-Match"), etag) {
+			for _, key := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+				w.Header().Del(key)
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buffer.body.Len()))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buffer.body.Bytes())
+	})
+}
+
+// matchEtag reports whether the If-None-Match values hold etag, using the
+// weak comparison RFC 9110 requires for If-None-Match.
+func matchEtag(values []string, etag string) bool {
+	for _, value := range values {
+		for candidate := range strings.SplitSeq(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds the response of a handler until its ETag
+// is known. Headers are written through to the underlying writer.
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
@@ -0,0 +1,147 @@
+package mizuconnect_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizuconnect"
+)
+
+const _CACHE_SERVICE = "/mizuconnect.test.v1.CacheService/"
+
+type cacheService interface {
+	Get(context.Context, *connect.Request[structpb.Value]) (*connect.Response[structpb.Value], error)
+}
+
+type cacheServiceImpl struct {
+	value string
+}
+
+func (s *cacheServiceImpl) Get(
+	_ context.Context, _ *connect.Request[structpb.Value],
+) (*connect.Response[structpb.Value], error) {
+	return connect.NewResponse(structpb.NewStringValue(s.value)), nil
+}
+
+func newCacheServiceHandler(svc cacheService, opts ...connect.HandlerOption) (string, http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(_CACHE_SERVICE+"Get", connect.NewUnaryHandler(_CACHE_SERVICE+"Get", svc.Get,
+		append(opts, connect.WithIdempotency(connect.IdempotencyNoSideEffects))...))
+	mux.Handle(_CACHE_SERVICE+"Put", connect.NewUnaryHandler(_CACHE_SERVICE+"Put", svc.Get,
+		append(opts, connect.WithIdempotency(connect.IdempotencyNoSideEffects))...))
+	return _CACHE_SERVICE, mux
+}
+
+func init() {
+	value := "google.protobuf.Value"
+	getOptions := &descriptorpb.MethodOptions{IdempotencyLevel: descriptorpb.MethodOptions_NO_SIDE_EFFECTS.Enum()}
+	proto.SetExtension(getOptions, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Post{Post: "/v1/cache"}, Body: "*",
+	})
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("mizuconnect/test/v1/cache.proto"),
+		Package:    proto.String("mizuconnect.test.v1"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		Syntax:     proto.String("proto3"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("CacheService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name: proto.String("Get"), InputType: proto.String("." + value), OutputType: proto.String("." + value),
+					Options: getOptions,
+				},
+				{Name: proto.String("Put"), InputType: proto.String("." + value), OutputType: proto.String("." + value)},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
+
+func TestMizuConnect_WithCrpcConditionalGet(t *testing.T) {
+	srv := mizu.NewServer("test")
+	impl := &cacheServiceImpl{value: "mizu"}
+	scope := mizuconnect.NewScope(srv,
+		mizuconnect.WithCrpcCachePolicy(_CACHE_SERVICE+"Get",
+			mizuconnect.CachePolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour, Public: true}),
+	)
+	scope.Register(impl, newCacheServiceHandler)
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+
+	get := func(method, etag string) *http.Response {
+		query := url.Values{"encoding": {"json"}, "message": {`"req"`}, "connect": {"v1"}}
+		req, err := http.NewRequest(http.MethodGet, server.URL+_CACHE_SERVICE+method+"?"+query.Encode(), nil)
+		require.NoError(t, err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("Get", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `"mizu"`, string(body))
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=60, s-maxage=3600", resp.Header.Get("Cache-Control"))
+
+	resp = get("Get", `"other", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	impl.value = "changed"
+	resp = get("Get", etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	resp = get("Put", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("ETag"), "procedures not declared free of side effects are left alone")
+}
+
+func TestMizuConnect_CachePolicy(t *testing.T) {
+	cases := []struct {
+		policy mizuconnect.CachePolicy
+		want   string
+	}{
+		{mizuconnect.CachePolicy{}, "private, no-cache"},
+		{mizuconnect.CachePolicy{MaxAge: time.Minute}, "private, max-age=60"},
+		{mizuconnect.CachePolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour}, "private, max-age=60"},
+		{mizuconnect.CachePolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour, Public: true},
+			"public, max-age=60, s-maxage=3600"},
+		{mizuconnect.CachePolicy{MaxAge: time.Second, StaleWhileRevalidate: time.Minute, Public: true},
+			"public, max-age=1, stale-while-revalidate=60"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.policy.String())
+	}
+}
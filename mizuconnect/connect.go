@@ -2,14 +2,16 @@ package mizuconnect
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"path"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 
 	"connectrpc.com/connect"
-	"connectrpc.com/grpchealth"
 	"connectrpc.com/grpcreflect"
 	"connectrpc.com/validate"
 	"connectrpc.com/vanguard"
@@ -45,6 +47,8 @@ type config struct {
 	suffix      string
 	connectOpts []connect.HandlerOption
 
+	enableCrpcValidate bool
+
 	enableGrpcHealth bool
 
 	enableGrpcReflect bool
@@ -59,6 +63,12 @@ type config struct {
 	gatewayPort       string
 	gatewayPattern    string
 	gatewayContext    context.Context
+
+	enableConditionalGet bool
+	defaultCachePolicy   CachePolicy
+	cachePolicies        []cachePolicy
+
+	procedureMiddlewares []procedureMiddleware
 }
 
 // Option configures the mizuconnect scope.
@@ -69,11 +79,15 @@ type Option func(*config)
 func WithCrpcValidate() Option {
 	return func(m *config) {
 		interceptor := validate.NewInterceptor()
+		m.enableCrpcValidate = true
 		m.connectOpts = append(m.connectOpts, connect.WithInterceptors(interceptor))
 	}
 }
 
-// WithGrpcHealth enables gRPC health checks for the registered
+// WithGrpcHealth enables gRPC health checks, including Watch streams,
+// for the registered services. Services report SERVING until they set
+// another status through Scope.HealthChecker, and all of them report
+// NOT_SERVING once the server starts shutting down.
 func WithGrpcHealth() Option {
 	return func(m *config) {
 		m.enableGrpcHealth = true
@@ -156,6 +170,20 @@ func WithSuffix(suffix string) Option {
 	}
 }
 
+type grpcHealth struct {
+	once    sync.Once
+	checker *HealthChecker
+}
+
+// transcoderPattern returns pattern, or the default transcoder pattern
+// of the scope if it is empty.
+func (c *config) transcoderPattern(pattern string) string {
+	if pattern != "" {
+		return pattern
+	}
+	return path.Join(c.prefix, "/", c.suffix)
+}
+
 // Scope is a mizu scope for Connect RPC services over mizu.Server.
 // Multiple scopes can be derived from a single mizu.Server as long as
 // the routes are well-managed. Transcoder like Vanguard and gRPC-gateway
@@ -165,6 +193,7 @@ type Scope struct {
 
 	config           *config
 	vanguardServices []*vanguard.Service
+	services         []ServiceReport
 }
 
 // NewScope creates a new Connect RPC scope with the given mizu server.
@@ -203,33 +232,34 @@ func NewScope(srv *mizu.Server, opts ...Option) *Scope {
 	}
 
 	if config.enableGrpcHealth {
-		once := sync.Once{}
-		mizu.Hook(srv, _CTXKEY_GRPC_HEALTH, &once, mizu.WithHookHandler(func(srv *mizu.Server) {
-			once.Do(func() {
-				checker := grpchealth.NewStaticChecker(*serviceNames...)
-				if scope.config.suffix == "" {
-					srv.Handle(grpchealth.NewHandler(checker))
-				} else {
-					pc, hc := grpchealth.NewHandler(checker)
-					srv.Handle(path.Join(pc, scope.config.suffix), hc)
-				}
-			})
-		}))
+		var health *grpcHealth
+		health = mizu.Hook(srv, _CTXKEY_GRPC_HEALTH, &grpcHealth{checker: NewHealthChecker()},
+			mizu.WithHookHandler(func(srv *mizu.Server) {
+				health.once.Do(func() {
+					health.checker.register(*serviceNames...)
+					if scope.config.suffix == "" {
+						srv.Handle(NewHealthHandler(health.checker))
+					} else {
+						pc, hc := NewHealthHandler(health.checker)
+						srv.Handle(path.Join(pc, scope.config.suffix), hc)
+					}
+				})
+			}),
+			mizu.WithHookShutdown(func(*mizu.Server) {
+				health.checker.Drain()
+			}),
+		)
 	}
 
 	if config.enableCrpcVanguard {
 		once := sync.Once{}
 		mizu.Hook(srv, _CTXKEY_CRPC_VANGUARD, &once, mizu.WithHookHandler(func(srv *mizu.Server) {
 			once.Do(func() {
-				pattern := path.Join(scope.config.prefix, "/", scope.config.suffix)
-				if scope.config.vanguardPattern != "" {
-					pattern = scope.config.vanguardPattern
-				}
 				transcoder, err := vanguard.NewTranscoder(scope.vanguardServices, scope.config.vanguardTranscoderOpts...)
 				if err != nil {
 					panic(err)
 				}
-				srv.Handle(pattern, transcoder)
+				srv.Handle(scope.config.transcoderPattern(scope.config.vanguardPattern), transcoder)
 			})
 		}))
 	}
@@ -238,11 +268,7 @@ func NewScope(srv *mizu.Server, opts ...Option) *Scope {
 		once := sync.Once{}
 		mizu.Hook(srv, _CTXKEY_GRPC_GATEWAY, &once, mizu.WithHookHandler(func(srv *mizu.Server) {
 			once.Do(func() {
-				pattern := path.Join(scope.config.prefix, "/", scope.config.suffix)
-				if scope.config.gatewayPattern != "" {
-					pattern = scope.config.gatewayPattern
-				}
-				srv.Handle(pattern, scope.config.gatewayMux)
+				srv.Handle(scope.config.transcoderPattern(scope.config.gatewayPattern), scope.config.gatewayMux)
 			})
 		}))
 	}
@@ -250,12 +276,77 @@ func NewScope(srv *mizu.Server, opts ...Option) *Scope {
 	return scope
 }
 
+// Errors wrapped by RegisterError.
+var (
+	// ErrInvalidConstructor reports that newFunc is not a Connect handler
+	// constructor taking impl.
+	ErrInvalidConstructor = errors.New("invalid connect handler constructor")
+	// ErrDescriptorNotFound reports that the service is missing from
+	// protoregistry.GlobalFiles.
+	ErrDescriptorNotFound = errors.New("service descriptor not found")
+	// ErrGatewayDisabled reports a UseGateway registration in a scope
+	// without WithGrpcGateway.
+	ErrGatewayDisabled = errors.New("gRPC-gateway is not enabled")
+	// ErrGatewayRegister reports that the gRPC-gateway register function
+	// failed.
+	ErrGatewayRegister = errors.New("gRPC-gateway registration failed")
+)
+
+// RegisterError describes a service that could not be registered.
+type RegisterError struct {
+	// Pattern is the service pattern, empty if it is not known yet.
+	Pattern string
+	// Reason details the failure.
+	Reason string
+	// Err is one of the Err variables, possibly wrapping the cause.
+	Err error
+}
+
+func (e RegisterError) Error() string {
+	msg := "register"
+	if e.Pattern != "" {
+		msg += " " + e.Pattern
+	}
+	msg += ": " + e.Err.Error()
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e RegisterError) Unwrap() error {
+	return e.Err
+}
+
+// ServiceReport describes a service registered in a scope.
+type ServiceReport struct {
+	// Name is the fully-qualified service name.
+	Name string
+	// Procedures are the full procedure names of the service methods.
+	Procedures []string
+	// ConnectPath is where the Connect handler is mounted.
+	ConnectPath string
+	// VanguardPath is where the Vanguard transcoder serving the service is
+	// mounted, empty without Vanguard.
+	VanguardPath string
+	// GatewayPath is where the gRPC-gateway proxying the service is
+	// mounted, empty unless registered through UseGateway.
+	GatewayPath string
+	// Validate reports whether WithCrpcValidate applies to the service.
+	Validate bool
+	// Health and Reflect report whether the gRPC health and reflection
+	// handlers of the server serve the service.
+	Health  bool
+	Reflect bool
+}
+
 // Register registers a Connect RPC service with the scope. impl is
 // the service implementation, newFunc is the generated Connect
 // constructor (e.g., greetv1connect.NewGreetServiceHandler), and opts
 // are additional handler options. The service is automatically
 // configured with validation, health checks, reflection, and Vanguard
-// transcoding based on the scope's configuration.
+// transcoding based on the scope's configuration. It panics where
+// TryRegister returns an error.
 //
 // Example:
 //
@@ -263,27 +354,117 @@ func NewScope(srv *mizu.Server, opts ...Option) *Scope {
 //	impl := &GreetServiceImpl{}
 //	scope.Register(impl, greetv1connect.NewGreetServiceHandler)
 func (s *Scope) Register(impl any, newFunc any, opts ...connect.HandlerOption) {
+	if err := s.TryRegister(impl, newFunc, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister is Register returning a RegisterError, instead of
+// panicking, when newFunc is not a Connect handler constructor for impl
+// or the service descriptor is not registered. Nothing is registered
+// when it fails.
+func (s *Scope) TryRegister(impl any, newFunc any, opts ...connect.HandlerOption) error {
+	pattern, handler, sd, err := s.build(impl, newFunc, opts...)
+	if err != nil {
+		return err
+	}
+	s.mount(pattern, handler, sd)
+	return nil
+}
+
+// build constructs the handler of impl with the scope handler options
+// and looks up its service descriptor, registering nothing yet.
+func (s *Scope) build(
+	impl any, newFunc any, opts ...connect.HandlerOption,
+) (string, http.Handler, protoreflect.ServiceDescriptor, error) {
 	opts = append(opts, s.config.connectOpts...)
 
-	pattern, handler := invoke(impl, newFunc, opts...)
-	fullyQualifiedServiceName, _ := detect(pattern)
+	pattern, handler, err := invoke(impl, newFunc, opts...)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	_, sd, err := detect(pattern)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return pattern, handler, sd, nil
+}
 
+// mount registers the handler built for the service sd on the server.
+func (s *Scope) mount(pattern string, handler http.Handler, sd protoreflect.ServiceDescriptor) {
+	fullyQualifiedServiceName := string(sd.FullName())
 	mizu.Immediate(s.srv, _CTXKEY_SERVICE_NAMES, func(v *[]string) {
 		*v = append(*v, fullyQualifiedServiceName)
 	})
 
 	// Register vanguard service
 	if s.config.enableCrpcVanguard {
-		vanService := vanguard.NewService(pattern, handler)
+		vanService := vanguard.NewService(pattern, s.config.procedure(pattern, sd, handler))
 		s.vanguardServices = append(s.vanguardServices, vanService)
 	}
 
 	// Register service
+	handler = s.config.procedure(pattern, sd, s.config.conditional(pattern, sd, handler))
+	mount := path.Join(s.config.prefix, pattern, s.config.suffix)
 	if s.config.suffix == "" {
-		s.srv.Handle(path.Join(s.config.prefix, pattern), handler)
-	} else {
-		s.srv.Handle(path.Join(s.config.prefix, pattern, s.config.suffix), handler)
+		// path.Join drops the trailing slash of the service pattern, which
+		// would leave only the bare service path routed.
+		mount = path.Join(s.config.prefix, pattern) + "/"
+	}
+	s.srv.Handle(mount, handler)
+	s.services = append(s.services, s.report(sd, mount))
+}
+
+// HealthChecker returns the health checker shared by the scopes of the
+// server, so that services can set their status at runtime, or nil if
+// no scope enables WithGrpcHealth.
+func (s *Scope) HealthChecker() *HealthChecker {
+	var checker *HealthChecker
+	mizu.Immediate(s.srv, _CTXKEY_GRPC_HEALTH, func(v *grpcHealth) {
+		if v != nil {
+			checker = v.checker
+		}
+	})
+	return checker
+}
+
+// Report lists the services registered in the scope in registration
+// order, so that startup can log or assert them. Health and reflection
+// are reported when any scope of the server enables them, as their
+// handlers serve every registered service.
+func (s *Scope) Report() []ServiceReport {
+	health := s.HealthChecker() != nil
+	var reflection bool
+	mizu.Immediate(s.srv, _CTXKEY_GRPC_REFLECT, func(v *sync.Once) { reflection = v != nil })
+
+	reports := make([]ServiceReport, len(s.services))
+	for i, report := range s.services {
+		report.Procedures = slices.Clone(report.Procedures)
+		report.Health, report.Reflect = health, reflection
+		reports[i] = report
+	}
+	return reports
+}
+
+// report describes the service of sd, with its Connect handler mounted
+// on pattern.
+func (s *Scope) report(sd protoreflect.ServiceDescriptor, pattern string) ServiceReport {
+	report := ServiceReport{
+		Name:        string(sd.FullName()),
+		ConnectPath: s.srv.Pattern(pattern),
+		Validate:    s.config.enableCrpcValidate,
+	}
+	if strings.HasSuffix(pattern, "/") {
+		report.ConnectPath += "/"
+	}
+	methods := sd.Methods()
+	for i := range methods.Len() {
+		report.Procedures = append(report.Procedures, "/"+report.Name+"/"+string(methods.Get(i).Name()))
+	}
+	if s.config.enableCrpcVanguard {
+		report.VanguardPath = s.config.transcoderPattern(s.config.vanguardPattern)
 	}
+	return report
 }
 
 type relayVanguardScope struct {
@@ -305,11 +486,26 @@ func (s *Scope) UseVanguard(svcOpts ...vanguard.ServiceOption) *relayVanguardSco
 
 // Register registers a Connect RPC service with the relay scope.
 // Which will apply vanguard service options to the registered service.
+// It panics where TryRegister returns an error.
 func (s relayVanguardScope) Register(impl any, newFunc any, opts ...connect.HandlerOption) {
+	if err := s.TryRegister(impl, newFunc, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister is Register returning a RegisterError instead of
+// panicking, like Scope.TryRegister.
+func (s relayVanguardScope) TryRegister(impl any, newFunc any, opts ...connect.HandlerOption) error {
 	opts = append(opts, s.inner.config.connectOpts...)
 
-	pattern, handler := invoke(impl, newFunc, opts...)
-	fullyQualifiedServiceName, _ := detect(pattern)
+	pattern, handler, err := invoke(impl, newFunc, opts...)
+	if err != nil {
+		return err
+	}
+	fullyQualifiedServiceName, sd, err := detect(pattern)
+	if err != nil {
+		return err
+	}
 
 	mizu.Immediate(s.inner.srv, _CTXKEY_SERVICE_NAMES, func(v *[]string) {
 		*v = append(*v, fullyQualifiedServiceName)
@@ -317,12 +513,15 @@ func (s relayVanguardScope) Register(impl any, newFunc any, opts ...connect.Hand
 
 	// Register vanguard service
 	if s.inner.config.enableCrpcVanguard {
-		vanService := vanguard.NewService(pattern, handler, s.svcOpts...)
+		vanService := vanguard.NewService(pattern, s.inner.config.procedure(pattern, sd, handler), s.svcOpts...)
 		s.inner.vanguardServices = append(s.inner.vanguardServices, vanService)
 	}
 
 	// Register service
+	handler = s.inner.config.procedure(pattern, sd, s.inner.config.conditional(pattern, sd, handler))
 	s.inner.srv.Handle(pattern, handler)
+	s.inner.services = append(s.inner.services, s.inner.report(sd, pattern))
+	return nil
 }
 
 type relayGatewayScope struct {
@@ -345,35 +544,56 @@ func (s *Scope) UseGateway(
 
 // Register registers a Connect RPC service with the relay scope.
 // Which will apply gRPC gateway configuration to the registered service.
+// It panics where TryRegister returns an error.
 func (r *relayGatewayScope) Register(impl any, newFunc any, opts ...connect.HandlerOption) {
+	if err := r.TryRegister(impl, newFunc, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister is Register returning a RegisterError instead of
+// panicking, like Scope.TryRegister. It also fails when the scope has no
+// gRPC gateway or its register function fails.
+func (r *relayGatewayScope) TryRegister(impl any, newFunc any, opts ...connect.HandlerOption) error {
 	if r.inner.config.gatewayMux == nil {
-		panic("gRPC-gateway is not enabled")
+		return RegisterError{Err: ErrGatewayDisabled}
 	}
 
+	// Validate the service before the gateway routes reach the shared
+	// mux, which cannot take them back.
+	pattern, handler, sd, err := r.inner.build(impl, newFunc, opts...)
+	if err != nil {
+		return err
+	}
 	if err := r.registerFunc(
 		r.inner.config.gatewayContext,
 		r.inner.config.gatewayMux, "127.0.0.1"+r.inner.config.gatewayPort, r.dialOpts,
 	); err != nil {
-		panic(err)
+		return RegisterError{Err: fmt.Errorf("%w: %w", ErrGatewayRegister, err)}
 	}
-	r.inner.Register(impl, newFunc, opts...)
+	r.inner.mount(pattern, handler, sd)
+	r.inner.services[len(r.inner.services)-1].GatewayPath =
+		r.inner.config.transcoderPattern(r.inner.config.gatewayPattern)
+	return nil
 }
 
 // detect extracts the protobuf service descriptor from the Connect
 // service pattern. It looks up the service in the global protobuf
 // registry to enable features like health checks and reflection.
-func detect(pattern string) (string, protoreflect.ServiceDescriptor) {
+func detect(pattern string) (string, protoreflect.ServiceDescriptor, error) {
 	nameSvc := strings.Trim(pattern, "/")
 	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(nameSvc))
 	if err != nil {
-		panic("descriptor not found:" + " " + nameSvc)
+		return "", nil, RegisterError{Pattern: pattern, Reason: err.Error(), Err: ErrDescriptorNotFound}
 	}
 
 	sd, ok := d.(protoreflect.ServiceDescriptor)
 	if !ok {
-		panic("descriptor not indicates service:" + " " + nameSvc)
+		return "", nil, RegisterError{
+			Pattern: pattern, Reason: nameSvc + " is not a service", Err: ErrDescriptorNotFound,
+		}
 	}
-	return nameSvc, sd
+	return nameSvc, sd, nil
 }
 
 // invoke dynamically calls the Connect handler constructor function
@@ -381,47 +601,54 @@ func detect(pattern string) (string, protoreflect.ServiceDescriptor) {
 // then returns the service pattern and HTTP handler. This allows for
 // type-safe registration of any Connect service without requiring
 // code generation for each service type.
-func invoke(impl any, newFunc any, opts ...connect.HandlerOption) (string, http.Handler) {
+func invoke(impl any, newFunc any, opts ...connect.HandlerOption) (string, http.Handler, error) {
+	invalid := func(reason string) (string, http.Handler, error) {
+		return "", nil, RegisterError{Reason: reason, Err: ErrInvalidConstructor}
+	}
+
 	reflectImpl := reflect.ValueOf(impl)
 	reflectFunc := reflect.ValueOf(newFunc)
 
 	if reflectFunc.Kind() != reflect.Func {
-		panic("newFunc must be a function")
+		return invalid("newFunc must be a function")
+	}
+	if !reflectImpl.IsValid() {
+		return invalid("service implementation is nil")
 	}
 
 	// Ensure legal input signature
 	if reflectFunc.Type().NumIn() != 2 {
-		panic("connect NewHandler function take 2 argument")
+		return invalid("connect NewHandler function take 2 argument")
 	}
 	reflectFuncArg1 := reflectFunc.Type().In(0)
 	reflectFuncArg2 := reflectFunc.Type().In(1)
 
 	// check first argument qualification
 	if !reflectImpl.Type().Implements(reflectFuncArg1) {
-		panic("first argument of connect NewHandler function must be the service implementation")
+		return invalid("first argument of connect NewHandler function must be the service implementation")
 	}
 
 	// check second argument qualification
 	if reflectFuncArg2.Kind() != reflect.Slice ||
 		!reflectFuncArg2.Elem().Implements(reflect.TypeOf((*connect.HandlerOption)(nil)).Elem()) {
-		panic("second argument of connect NewHandler function must be elipses slice of connect.HandlerOption")
+		return invalid("second argument of connect NewHandler function must be elipses slice of connect.HandlerOption")
 	}
 
 	// Ensure legal output signature
 	if reflectFunc.Type().NumOut() != 2 {
-		panic("connect NewHandler function must return 2 values")
+		return invalid("connect NewHandler function must return 2 values")
 	}
 	reflectFuncRet1 := reflectFunc.Type().Out(0)
 	reflectFuncRet2 := reflectFunc.Type().Out(1)
 
 	// check first return value
 	if reflectFuncRet1.Kind() != reflect.String {
-		panic("first return value of connect NewHandler function must be a string")
+		return invalid("first return value of connect NewHandler function must be a string")
 	}
 
 	// check second return value
 	if !reflectFuncRet2.Implements(reflect.TypeOf((*http.Handler)(nil)).Elem()) {
-		panic("second return value of connect NewHandler function must be http.Handler")
+		return invalid("second return value of connect NewHandler function must be http.Handler")
 	}
 
 	// Call connect NewHandler function
@@ -431,5 +658,5 @@ func invoke(impl any, newFunc any, opts ...connect.HandlerOption) (string, http.
 	}
 	ret := reflectFunc.Call(args)
 
-	return ret[0].Interface().(string), ret[1].Interface().(http.Handler)
+	return ret[0].Interface().(string), ret[1].Interface().(http.Handler), nil
 }
@@ -0,0 +1,479 @@
+package mizuconnect
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/humbornjo/mizu"
+)
+
+// ProtoMask is an immutable set of field paths bound to the message type
+// M, applied directly on messages through protoreflect. Paths are stored
+// with proto field names, as carried by google.protobuf.FieldMask.
+//
+// A path segment following a map field selects a map key. Repeated
+// message fields are transparent, a path through them applies to every
+// element. Oneof members are addressed by their field name like any other
+// field.
+type ProtoMask[M proto.Message] struct {
+	desc  protoreflect.MessageDescriptor
+	paths []string
+	json  []string
+	root  *protoMaskNode
+}
+
+type protoMaskNode struct {
+	selected bool
+	children map[string]*protoMaskNode
+}
+
+// NewProtoMask returns a mask of the given proto name paths, such as
+// `display_name` or `labels.env`. Every invalid path is reported in the
+// returned mizu.FieldMaskErrors.
+func NewProtoMask[M proto.Message](paths ...string) (*ProtoMask[M], error) {
+	return newProtoMask[M](paths, false)
+}
+
+// FromFieldMask returns the mask carried by a google.protobuf.FieldMask,
+// typically the `update_mask` of an UpdateX request. A nil field mask
+// yields an empty mask.
+func FromFieldMask[M proto.Message](mask *fieldmaskpb.FieldMask) (*ProtoMask[M], error) {
+	return newProtoMask[M](mask.GetPaths(), false)
+}
+
+// FromJsonPaths returns the mask of JSON name paths, such as
+// `displayName`, as produced by mizu.FieldMask for protojson payloads.
+// Proto names are accepted as well, since they are the JSON names of the
+// generated Go structs.
+func FromJsonPaths[M proto.Message](paths ...string) (*ProtoMask[M], error) {
+	return newProtoMask[M](paths, true)
+}
+
+// IntersectFieldMask validates the paths of mask against M and intersects
+// their canonical proto names with allowed through mizu.Intersect, for
+// handlers that apply the mask on a Go struct T mirroring M. Both T and
+// allowed use proto names, like the json tags of the generated Go
+// structs; intersect JsonPaths instead for structs tagged with protojson
+// names.
+func IntersectFieldMask[T any, M proto.Message](
+	allowed []string, mask *fieldmaskpb.FieldMask,
+) (*mizu.FieldMask[T], error) {
+	protoMask, err := FromFieldMask[M](mask)
+	if err != nil {
+		return nil, err
+	}
+	return mizu.Intersect[T](allowed, protoMask.Paths()), nil
+}
+
+func newProtoMask[M proto.Message](paths []string, byJson bool) (*ProtoMask[M], error) {
+	var zero M
+	mask := &ProtoMask[M]{desc: zero.ProtoReflect().Descriptor(), root: newProtoMaskNode()}
+
+	var errs mizu.FieldMaskErrors
+	protoPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		resolved, reason := resolveProtoMaskPath(mask.desc, path, byJson)
+		if reason != "" {
+			errs = append(errs, mizu.FieldMaskError{Path: path, Reason: reason})
+			continue
+		}
+		protoPaths = append(protoPaths, resolved)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	mask.paths = normalizeProtoMaskPaths(protoPaths)
+	mask.json = make([]string, 0, len(mask.paths))
+	for _, path := range mask.paths {
+		parts := strings.Split(path, ".")
+		mask.root.add(parts)
+		mask.json = append(mask.json, jsonProtoMaskPath(mask.desc, parts))
+	}
+	return mask, nil
+}
+
+// Paths returns a copy of the canonical proto name paths in the mask.
+func (m *ProtoMask[M]) Paths() []string {
+	if m == nil {
+		return nil
+	}
+	return slices.Clone(m.paths)
+}
+
+// JsonPaths returns the paths in the mask with protojson field names, in
+// the same order as Paths.
+func (m *ProtoMask[M]) JsonPaths() []string {
+	if m == nil {
+		return nil
+	}
+	return slices.Clone(m.json)
+}
+
+// FieldMask returns the mask as a google.protobuf.FieldMask.
+func (m *ProtoMask[M]) FieldMask() *fieldmaskpb.FieldMask {
+	return &fieldmaskpb.FieldMask{Paths: m.Paths()}
+}
+
+// Filter keeps fields selected by the mask and clears all other fields.
+// An empty mask clears every field.
+func (m *ProtoMask[M]) Filter(msg M) error {
+	target, err := m.target(msg, "filter")
+	if err != nil {
+		return err
+	}
+	filterProtoMessage(target, m.root)
+	return nil
+}
+
+// Prune clears fields selected by the mask and leaves all other fields
+// untouched. An empty mask is a no-op.
+func (m *ProtoMask[M]) Prune(msg M) error {
+	target, err := m.target(msg, "prune")
+	if err != nil {
+		return err
+	}
+	pruneProtoMessage(target, m.root)
+	return nil
+}
+
+// Overwrite copies fields selected by the mask from src to dest and leaves
+// all other destination fields untouched. Selected fields absent in src are
+// cleared in dest. Copied values are deep clones and never alias src. An
+// empty mask is a no-op.
+func (m *ProtoMask[M]) Overwrite(src, dest M) error {
+	source, err := m.target(src, "overwrite source")
+	if err != nil {
+		return err
+	}
+	target, err := m.target(dest, "overwrite destination")
+	if err != nil {
+		return err
+	}
+	overwriteProtoMessage(source, target, m.root)
+	return nil
+}
+
+func (m *ProtoMask[M]) target(msg M, operation string) (protoreflect.Message, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%s: field mask is nil", operation)
+	}
+	if any(msg) == nil {
+		return nil, fmt.Errorf("%s: message is nil", operation)
+	}
+	target := msg.ProtoReflect()
+	if !target.IsValid() {
+		return nil, fmt.Errorf("%s: message is nil", operation)
+	}
+	if target.Descriptor().FullName() != m.desc.FullName() {
+		return nil, errors.New(operation + ": message type does not match the field mask")
+	}
+	return target, nil
+}
+
+func newProtoMaskNode() *protoMaskNode {
+	return &protoMaskNode{children: make(map[string]*protoMaskNode)}
+}
+
+func (n *protoMaskNode) add(parts []string) {
+	current := n
+	for _, part := range parts {
+		if current.selected {
+			return
+		}
+		child := current.children[part]
+		if child == nil {
+			child = newProtoMaskNode()
+			current.children[part] = child
+		}
+		current = child
+	}
+	current.selected = true
+	current.children = nil
+}
+
+// resolveProtoMaskPath resolves a dotted path against desc and returns it
+// with proto field names and canonical map keys, or the reason it is
+// invalid.
+func resolveProtoMaskPath(desc protoreflect.MessageDescriptor, path string, byJson bool) (string, string) {
+	parts := strings.Split(path, ".")
+	if slices.Contains(parts, "") {
+		return "", "empty path segment"
+	}
+
+	resolved := make([]string, 0, len(parts))
+	for index := 0; index < len(parts); index++ {
+		if desc == nil {
+			return "", "unknown field"
+		}
+		field := lookupProtoMaskField(desc, parts[index], byJson)
+		if field == nil {
+			return "", "unknown field"
+		}
+		resolved = append(resolved, string(field.Name()))
+		desc = field.Message()
+		if !field.IsMap() || index+1 == len(parts) {
+			continue
+		}
+
+		index++
+		key, ok := parseProtoMapKey(field.MapKey(), parts[index])
+		if !ok {
+			return "", "invalid map key"
+		}
+		resolved = append(resolved, key.String())
+		desc = field.MapValue().Message()
+	}
+	return strings.Join(resolved, "."), ""
+}
+
+func lookupProtoMaskField(
+	desc protoreflect.MessageDescriptor, name string, byJson bool,
+) protoreflect.FieldDescriptor {
+	fields := desc.Fields()
+	if byJson {
+		if field := fields.ByJSONName(name); field != nil {
+			return field
+		}
+	}
+	return fields.ByName(protoreflect.Name(name))
+}
+
+// jsonProtoMaskPath renames the fields of a resolved path to their JSON
+// names, keeping map keys verbatim.
+func jsonProtoMaskPath(desc protoreflect.MessageDescriptor, parts []string) string {
+	json := slices.Clone(parts)
+	for index := 0; index < len(parts); index++ {
+		field := desc.Fields().ByName(protoreflect.Name(parts[index]))
+		json[index] = field.JSONName()
+		desc = field.Message()
+		if field.IsMap() && index+1 < len(parts) {
+			index++
+			desc = field.MapValue().Message()
+		}
+	}
+	return strings.Join(json, ".")
+}
+
+func parseProtoMapKey(field protoreflect.FieldDescriptor, raw string) (protoreflect.MapKey, bool) {
+	var value protoreflect.Value
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		value = protoreflect.ValueOfString(raw)
+	case protoreflect.BoolKind:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protoreflect.MapKey{}, false
+		}
+		value = protoreflect.ValueOfBool(parsed)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		parsed, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, false
+		}
+		value = protoreflect.ValueOfInt32(int32(parsed))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, false
+		}
+		value = protoreflect.ValueOfInt64(parsed)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, false
+		}
+		value = protoreflect.ValueOfUint32(uint32(parsed))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, false
+		}
+		value = protoreflect.ValueOfUint64(parsed)
+	default:
+		return protoreflect.MapKey{}, false
+	}
+	return value.MapKey(), true
+}
+
+func normalizeProtoMaskPaths(paths []string) []string {
+	slices.Sort(paths)
+	result := paths[:0]
+	for _, path := range paths {
+		if len(result) > 0 {
+			last := result[len(result)-1]
+			if path == last || strings.HasPrefix(path, last+".") {
+				continue
+			}
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+func filterProtoMessage(msg protoreflect.Message, node *protoMaskNode) {
+	msg.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		child := node.children[string(field.Name())]
+		switch {
+		case child == nil:
+			msg.Clear(field)
+		case child.selected:
+		case field.IsMap():
+			filterProtoMap(msg.Mutable(field).Map(), child)
+		case field.IsList():
+			list := msg.Mutable(field).List()
+			for i := range list.Len() {
+				filterProtoMessage(list.Get(i).Message(), child)
+			}
+		default:
+			filterProtoMessage(msg.Mutable(field).Message(), child)
+		}
+		return true
+	})
+}
+
+func filterProtoMap(items protoreflect.Map, node *protoMaskNode) {
+	items.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		child := node.children[key.String()]
+		switch {
+		case child == nil:
+			items.Clear(key)
+		case child.selected:
+		default:
+			filterProtoMessage(items.Mutable(key).Message(), child)
+		}
+		return true
+	})
+}
+
+func pruneProtoMessage(msg protoreflect.Message, node *protoMaskNode) {
+	fields := msg.Descriptor().Fields()
+	for name, child := range node.children {
+		field := fields.ByName(protoreflect.Name(name))
+		if !msg.Has(field) {
+			continue
+		}
+		switch {
+		case child.selected:
+			msg.Clear(field)
+		case field.IsMap():
+			items := msg.Mutable(field).Map()
+			for name, grandchild := range child.children {
+				key, _ := parseProtoMapKey(field.MapKey(), name)
+				switch {
+				case !items.Has(key):
+				case grandchild.selected:
+					items.Clear(key)
+				default:
+					pruneProtoMessage(items.Mutable(key).Message(), grandchild)
+				}
+			}
+		case field.IsList():
+			list := msg.Mutable(field).List()
+			for i := range list.Len() {
+				pruneProtoMessage(list.Get(i).Message(), child)
+			}
+		default:
+			pruneProtoMessage(msg.Mutable(field).Message(), child)
+		}
+	}
+}
+
+func overwriteProtoMessage(source, target protoreflect.Message, node *protoMaskNode) {
+	fields := target.Descriptor().Fields()
+	for name, child := range node.children {
+		field := fields.ByName(protoreflect.Name(name))
+		if child.selected {
+			if source.Has(field) {
+				target.Set(field, cloneProtoField(target, field, source.Get(field)))
+			} else {
+				target.Clear(field)
+			}
+			continue
+		}
+		if !source.Has(field) && !target.Has(field) {
+			continue
+		}
+
+		switch {
+		case field.IsMap():
+			overwriteProtoMap(source.Get(field).Map(), target.Mutable(field).Map(), field, child)
+		case field.IsList():
+			sourceList, targetList := source.Get(field).List(), target.Mutable(field).List()
+			if targetList.Len() > sourceList.Len() {
+				targetList.Truncate(sourceList.Len())
+			}
+			for targetList.Len() < sourceList.Len() {
+				targetList.AppendMutable()
+			}
+			for i := range sourceList.Len() {
+				overwriteProtoMessage(sourceList.Get(i).Message(), targetList.Get(i).Message(), child)
+			}
+		default:
+			overwriteProtoMessage(source.Get(field).Message(), target.Mutable(field).Message(), child)
+		}
+	}
+}
+
+func overwriteProtoMap(source, target protoreflect.Map, field protoreflect.FieldDescriptor, node *protoMaskNode) {
+	for name, child := range node.children {
+		key, _ := parseProtoMapKey(field.MapKey(), name)
+		if child.selected {
+			if source.Has(key) {
+				target.Set(key, cloneProtoValue(source.Get(key)))
+			} else {
+				target.Clear(key)
+			}
+			continue
+		}
+		if !source.Has(key) && !target.Has(key) {
+			continue
+		}
+		item := target.NewValue()
+		if source.Has(key) {
+			item = source.Get(key)
+		}
+		overwriteProtoMessage(item.Message(), target.Mutable(key).Message(), child)
+	}
+}
+
+// cloneProtoField deep clones value of field so that it can be set on
+// target without aliasing its source.
+func cloneProtoField(
+	target protoreflect.Message, field protoreflect.FieldDescriptor, value protoreflect.Value,
+) protoreflect.Value {
+	switch {
+	case field.IsList():
+		clone := target.NewField(field).List()
+		list := value.List()
+		for i := range list.Len() {
+			clone.Append(cloneProtoValue(list.Get(i)))
+		}
+		return protoreflect.ValueOfList(clone)
+	case field.IsMap():
+		clone := target.NewField(field).Map()
+		value.Map().Range(func(key protoreflect.MapKey, item protoreflect.Value) bool {
+			clone.Set(key, cloneProtoValue(item))
+			return true
+		})
+		return protoreflect.ValueOfMap(clone)
+	}
+	return cloneProtoValue(value)
+}
+
+func cloneProtoValue(value protoreflect.Value) protoreflect.Value {
+	switch inner := value.Interface().(type) {
+	case protoreflect.Message:
+		return protoreflect.ValueOfMessage(proto.Clone(inner.Interface()).ProtoReflect())
+	case []byte:
+		return protoreflect.ValueOfBytes(slices.Clone(inner))
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package mizuconnect_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizuconnect"
+)
+
+func newProtoMaskStruct(t *testing.T) *structpb.Struct {
+	t.Helper()
+
+	value, err := structpb.NewStruct(map[string]any{
+		"profile": map[string]any{"name": "mizu", "age": 3},
+		"score":   1.5,
+		"items": []any{
+			map[string]any{"label": "a", "count": 1},
+			map[string]any{"label": "b", "count": 2},
+		},
+	})
+	require.NoError(t, err)
+	return value
+}
+
+func TestMizuConnect_ProtoMaskConversion(t *testing.T) {
+	mask, err := mizuconnect.FromFieldMask[*descriptorpb.FileDescriptorProto](&fieldmaskpb.FieldMask{
+		Paths: []string{"message_type.field.json_name", "options.java_package", "message_type"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"message_type", "options.java_package"}, mask.Paths())
+	assert.Equal(t, []string{"messageType", "options.javaPackage"}, mask.JsonPaths())
+	assert.Equal(t, []string{"message_type", "options.java_package"}, mask.FieldMask().GetPaths())
+
+	mask, err = mizuconnect.FromJsonPaths[*descriptorpb.FileDescriptorProto](
+		"messageType.field.jsonName", "options.java_package",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"message_type.field.json_name", "options.java_package"}, mask.Paths())
+
+	structMask, err := mizuconnect.NewProtoMask[*structpb.Struct]("fields.key.struct_value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fields.key.structValue"}, structMask.JsonPaths())
+
+	_, err = mizuconnect.NewProtoMask[*descriptorpb.FileDescriptorProto](
+		"messageType", "name.length", "options..java_package", "dependency",
+	)
+	assert.Equal(t, mizu.FieldMaskErrors{
+		{Path: "messageType", Reason: "unknown field"},
+		{Path: "name.length", Reason: "unknown field"},
+		{Path: "options..java_package", Reason: "empty path segment"},
+	}, err)
+
+	// Generated Go structs carry proto names in their json tags.
+	type option struct {
+		JavaPackage string `json:"java_package,omitempty"`
+		GoPackage   string `json:"go_package,omitempty"`
+	}
+	type file struct {
+		Name    string  `json:"name,omitempty"`
+		Package string  `json:"package,omitempty"`
+		Options *option `json:"options,omitempty"`
+	}
+	intersected, err := mizuconnect.IntersectFieldMask[file, *descriptorpb.FileDescriptorProto](
+		[]string{"name", "options.go_package"},
+		&fieldmaskpb.FieldMask{Paths: []string{"options", "package", "name"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "options.go_package"}, intersected.Paths())
+	assert.True(t, intersected.Contains("options.go_package"))
+
+	_, err = mizuconnect.IntersectFieldMask[file, *descriptorpb.FileDescriptorProto](
+		[]string{"name"}, &fieldmaskpb.FieldMask{Paths: []string{"goPackage"}},
+	)
+	assert.Equal(t, mizu.FieldMaskErrors{{Path: "goPackage", Reason: "unknown field"}}, err)
+}
+
+func TestMizuConnect_ProtoMaskFilter(t *testing.T) {
+	mask, err := mizuconnect.NewProtoMask[*structpb.Struct](
+		"fields.profile.struct_value.fields.name",
+		"fields.items.list_value.values.struct_value.fields.label",
+		"fields.score.string_value",
+	)
+	require.NoError(t, err)
+
+	value := newProtoMaskStruct(t)
+	require.NoError(t, mask.Filter(value))
+	assert.Equal(t, map[string]any{
+		"profile": map[string]any{"name": "mizu"},
+		"items":   []any{map[string]any{"label": "a"}, map[string]any{"label": "b"}},
+		"score":   nil,
+	}, value.AsMap(), "oneof members outside the mask are cleared")
+
+	empty, err := mizuconnect.NewProtoMask[*structpb.Struct]()
+	require.NoError(t, err)
+	value = newProtoMaskStruct(t)
+	require.NoError(t, empty.Filter(value))
+	assert.Empty(t, value.GetFields())
+}
+
+func TestMizuConnect_ProtoMaskPrune(t *testing.T) {
+	mask, err := mizuconnect.NewProtoMask[*structpb.Struct](
+		"fields.profile.struct_value.fields.age",
+		"fields.items.list_value.values.struct_value.fields.count",
+		"fields.score",
+		"fields.missing",
+	)
+	require.NoError(t, err)
+
+	value := newProtoMaskStruct(t)
+	require.NoError(t, mask.Prune(value))
+	assert.Equal(t, map[string]any{
+		"profile": map[string]any{"name": "mizu"},
+		"items":   []any{map[string]any{"label": "a"}, map[string]any{"label": "b"}},
+	}, value.AsMap())
+}
+
+func TestMizuConnect_ProtoMaskOverwrite(t *testing.T) {
+	mask, err := mizuconnect.NewProtoMask[*structpb.Struct](
+		"fields.profile",
+		"fields.score.number_value",
+		"fields.items.list_value.values.struct_value.fields.count",
+		"fields.stale",
+	)
+	require.NoError(t, err)
+
+	source := newProtoMaskStruct(t)
+	target, err := structpb.NewStruct(map[string]any{
+		"score": "high",
+		"stale": true,
+		"kept":  "yes",
+		"items": []any{
+			map[string]any{"label": "x", "count": 9},
+			map[string]any{"label": "y"},
+			map[string]any{"label": "z"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, mask.Overwrite(source, target))
+	assert.Equal(t, map[string]any{
+		"profile": map[string]any{"name": "mizu", "age": float64(3)},
+		"score":   1.5,
+		"kept":    "yes",
+		"items":   []any{map[string]any{"label": "x", "count": float64(1)}, map[string]any{"label": "y", "count": float64(2)}},
+	}, target.AsMap())
+
+	source.GetFields()["profile"].GetStructValue().GetFields()["name"] = structpb.NewStringValue("changed")
+	assert.Equal(t, "mizu", target.GetFields()["profile"].GetStructValue().GetFields()["name"].GetStringValue(),
+		"overwritten values must not alias the source")
+	assert.True(t, proto.Equal(source.GetFields()["score"], target.GetFields()["score"]))
+}
+
+func TestMizuConnect_ProtoMaskErrors(t *testing.T) {
+	mask, err := mizuconnect.NewProtoMask[*structpb.Struct]("fields")
+	require.NoError(t, err)
+
+	var value *structpb.Struct
+	assert.EqualError(t, mask.Filter(value), "filter: message is nil")
+
+	var nilMask *mizuconnect.ProtoMask[*structpb.Struct]
+	assert.EqualError(t, nilMask.Prune(&structpb.Struct{}), "prune: field mask is nil")
+	assert.Nil(t, nilMask.Paths())
+}
@@ -0,0 +1,193 @@
+package mizuconnect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var _ grpchealth.Checker = (*HealthChecker)(nil)
+
+// HealthChecker is a grpchealth.Checker whose statuses change at
+// runtime. Once drained, it reports every service, and the server as a
+// whole, as NOT_SERVING.
+type HealthChecker struct {
+	mu       sync.Mutex
+	statuses map[string]grpchealth.Status
+	draining bool
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+// NewHealthChecker creates a HealthChecker reporting services as
+// SERVING.
+func NewHealthChecker(services ...string) *HealthChecker {
+	c := &HealthChecker{
+		statuses: make(map[string]grpchealth.Status, len(services)),
+		watchers: make(map[string]map[chan struct{}]struct{}),
+	}
+	for _, service := range services {
+		c.statuses[service] = grpchealth.StatusServing
+	}
+	return c
+}
+
+// SetStatus sets the status of service, a fully-qualified service name,
+// or of the server as a whole if service is empty. Watchers of service
+// are notified.
+func (c *HealthChecker) SetStatus(service string, status grpchealth.Status) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.statuses[service] = status
+	c.notify(service)
+}
+
+// Drain switches every service to NOT_SERVING for good, so that load
+// balancers stop routing to the server while it shuts down. Later
+// SetStatus calls are recorded but not reported.
+func (c *HealthChecker) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.draining = true
+	for service := range c.watchers {
+		c.notify(service)
+	}
+}
+
+// Check implements grpchealth.Checker.
+func (c *HealthChecker) Check(_ context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.status(req.Service)
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unknown service %s", req.Service))
+	}
+	return &grpchealth.CheckResponse{Status: status}, nil
+}
+
+// register sets services SERVING unless they already have a status.
+func (c *HealthChecker) register(services ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, service := range services {
+		if _, ok := c.statuses[service]; !ok {
+			c.statuses[service] = grpchealth.StatusServing
+			c.notify(service)
+		}
+	}
+}
+
+// status returns the reported status of service, or false if it is
+// unknown. c.mu must be held.
+func (c *HealthChecker) status(service string) (grpchealth.Status, bool) {
+	status, ok := c.statuses[service]
+	if !ok && service == "" {
+		status, ok = grpchealth.StatusServing, true
+	}
+	if ok && c.draining {
+		status = grpchealth.StatusNotServing
+	}
+	return status, ok
+}
+
+// notify wakes the watchers of service. c.mu must be held.
+func (c *HealthChecker) notify(service string) {
+	for ch := range c.watchers[service] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *HealthChecker) watch(service string) (chan struct{}, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if c.watchers[service] == nil {
+		c.watchers[service] = make(map[chan struct{}]struct{})
+	}
+	c.watchers[service][ch] = struct{}{}
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.watchers[service], ch)
+		if len(c.watchers[service]) == 0 {
+			delete(c.watchers, service)
+		}
+	}
+}
+
+// NewHealthHandler builds the handler of the gRPC health service over
+// checker, like grpchealth.NewHandler, with Watch streams supported. A
+// Watch stream sends the current status of its service, then every
+// change of it, until the client cancels it. Services unknown to checker
+// are reported as SERVICE_UNKNOWN.
+func NewHealthHandler(checker *HealthChecker, opts ...connect.HandlerOption) (string, http.Handler) {
+	const serviceName = "/" + grpchealth.HealthV1ServiceName + "/"
+	mux := http.NewServeMux()
+	mux.Handle(serviceName+"Check", connect.NewUnaryHandler(serviceName+"Check",
+		func(
+			ctx context.Context, req *connect.Request[healthpb.HealthCheckRequest],
+		) (*connect.Response[healthpb.HealthCheckResponse], error) {
+			resp, err := checker.Check(ctx, &grpchealth.CheckRequest{Service: req.Msg.GetService()})
+			if err != nil {
+				return nil, err
+			}
+			return connect.NewResponse(&healthpb.HealthCheckResponse{
+				Status: healthpb.HealthCheckResponse_ServingStatus(resp.Status),
+			}), nil
+		},
+		opts...,
+	))
+	mux.Handle(serviceName+"Watch", connect.NewServerStreamHandler(serviceName+"Watch",
+		func(
+			ctx context.Context, req *connect.Request[healthpb.HealthCheckRequest],
+			stream *connect.ServerStream[healthpb.HealthCheckResponse],
+		) error {
+			service := req.Msg.GetService()
+			changed, stop := checker.watch(service)
+			defer stop()
+
+			last := healthpb.HealthCheckResponse_ServingStatus(-1)
+			for {
+				checker.mu.Lock()
+				status, ok := checker.status(service)
+				draining := checker.draining
+				checker.mu.Unlock()
+
+				current := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+				if ok {
+					current = healthpb.HealthCheckResponse_ServingStatus(status)
+				}
+				if current != last {
+					if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+						return err
+					}
+					last = current
+				}
+				// Open streams would hold the graceful shutdown of the server.
+				if draining {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-changed:
+				}
+			}
+		},
+		opts...,
+	))
+	return serviceName, mux
+}
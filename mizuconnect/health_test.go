@@ -0,0 +1,111 @@
+package mizuconnect_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizuconnect"
+)
+
+func TestMizuConnect_HealthChecker(t *testing.T) {
+	ctx := context.Background()
+	checker := mizuconnect.NewHealthChecker("acme.v1.UserService")
+	check := func(service string) (grpchealth.Status, error) {
+		resp, err := checker.Check(ctx, &grpchealth.CheckRequest{Service: service})
+		if err != nil {
+			return 0, err
+		}
+		return resp.Status, nil
+	}
+
+	status, err := check("acme.v1.UserService")
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.StatusServing, status)
+	_, err = check("acme.v1.Unknown")
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	checker.SetStatus("acme.v1.UserService", grpchealth.StatusNotServing)
+	status, _ = check("acme.v1.UserService")
+	assert.Equal(t, grpchealth.StatusNotServing, status)
+	checker.SetStatus("acme.v1.UserService", grpchealth.StatusServing)
+
+	checker.Drain()
+	for _, service := range []string{"", "acme.v1.UserService"} {
+		status, err = check(service)
+		require.NoError(t, err)
+		assert.Equal(t, grpchealth.StatusNotServing, status)
+	}
+	checker.SetStatus("acme.v1.UserService", grpchealth.StatusServing)
+	status, _ = check("acme.v1.UserService")
+	assert.Equal(t, grpchealth.StatusNotServing, status, "draining is final")
+}
+
+func TestMizuConnect_NewHealthHandler(t *testing.T) {
+	checker := mizuconnect.NewHealthChecker("acme.v1.UserService")
+	mux := http.NewServeMux()
+	mux.Handle(mizuconnect.NewHealthHandler(checker))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := connect.NewClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse](
+		server.Client(), server.URL+"/grpc.health.v1.Health/Watch")
+	watch := func(service string) *connect.ServerStreamForClient[healthpb.HealthCheckResponse] {
+		stream, err := client.CallServerStream(context.Background(),
+			connect.NewRequest(&healthpb.HealthCheckRequest{Service: service}))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = stream.Close() })
+		return stream
+	}
+	next := func(stream *connect.ServerStreamForClient[healthpb.HealthCheckResponse]) healthpb.HealthCheckResponse_ServingStatus {
+		require.True(t, stream.Receive(), stream.Err())
+		return stream.Msg().GetStatus()
+	}
+
+	stream := watch("acme.v1.UserService")
+	unknown := watch("acme.v1.Unknown")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, next(stream))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVICE_UNKNOWN, next(unknown))
+
+	checker.SetStatus("acme.v1.UserService", grpchealth.StatusNotServing)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, next(stream))
+	checker.SetStatus("acme.v1.UserService", grpchealth.StatusServing)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, next(stream))
+
+	checker.Drain()
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, next(stream))
+	assert.False(t, stream.Receive(), "streams end once drained")
+	require.NoError(t, stream.Err())
+}
+
+func TestMizuConnect_WithGrpcHealth(t *testing.T) {
+	srv := mizu.NewServer("test")
+	scope := mizuconnect.NewScope(srv, mizuconnect.WithGrpcHealth())
+	scope.Register(&cacheServiceImpl{}, newCacheServiceHandler)
+	checker := scope.HealthChecker()
+	require.NotNil(t, checker)
+	assert.Nil(t, mizuconnect.NewScope(mizu.NewServer("test")).HealthChecker())
+
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	client := connect.NewClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse](
+		server.Client(), server.URL+"/grpc.health.v1.Health/Check")
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.CallUnary(context.Background(),
+			connect.NewRequest(&healthpb.HealthCheckRequest{Service: "mizuconnect.test.v1.CacheService"}))
+		require.NoError(t, err)
+		return resp.Msg.GetStatus()
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check())
+	checker.SetStatus("mizuconnect.test.v1.CacheService", grpchealth.StatusNotServing)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check())
+}
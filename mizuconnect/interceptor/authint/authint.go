@@ -0,0 +1,311 @@
+package authint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// HEADER_WWW_AUTHENTICATE is attached to CodeUnauthenticated errors as
+// error metadata, as RFC 6750 asks of bearer token resources.
+const HEADER_WWW_AUTHENTICATE = "WWW-Authenticate"
+
+type ctxkey int
+
+const _CTXKEY_CLAIMS ctxkey = iota
+
+// Claims are the verified claims of a bearer token.
+type Claims map[string]any
+
+// Subject returns the `sub` claim.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Issuer returns the `iss` claim.
+func (c Claims) Issuer() string {
+	iss, _ := c["iss"].(string)
+	return iss
+}
+
+// Audience returns the `aud` claim, which may be a single string or an
+// array of strings.
+func (c Claims) Audience() []string {
+	return claimStrings(c["aud"], false)
+}
+
+// Scopes returns the space separated `scope` claim, or the `scp` claim as
+// used by some providers.
+func (c Claims) Scopes() []string {
+	if scope, ok := c["scope"]; ok {
+		return claimStrings(scope, true)
+	}
+	return claimStrings(c["scp"], true)
+}
+
+func claimStrings(value any, split bool) []string {
+	switch value := value.(type) {
+	case string:
+		if split {
+			return strings.Fields(value)
+		}
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// ClaimsFromContext returns the claims of the verified bearer token of
+// the request. It reports false for anonymous requests.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(_CTXKEY_CLAIMS).(Claims)
+	return claims, ok
+}
+
+// Policy decides who may call a procedure.
+type Policy struct {
+	// Anonymous lets requests without a bearer token through. A token that
+	// is present must still be valid.
+	Anonymous bool
+	// Scopes lists the scopes a token must grant, all of them.
+	Scopes []string
+	// Authorize is called with the verified claims after the scope check.
+	// Returning false denies the request with CodePermissionDenied.
+	Authorize func(context.Context, Claims) bool
+}
+
+type rule struct {
+	pattern string
+	policy  Policy
+}
+
+type option func(*config)
+
+type config struct {
+	keys      []key
+	jwksFiles []string
+	issuers   []string
+	audiences []string
+	leeway    time.Duration
+	rules     []rule
+	fallback  Policy
+}
+
+// WithKey adds a verification key. The key is a []byte secret for HS256,
+// an *rsa.PublicKey for RS256 or an *ecdsa.PublicKey on P-256 for ES256.
+// Tokens carrying a `kid` header are verified with the keys of that id,
+// and with the keys added under an empty id otherwise.
+func WithKey(kid string, k any) option {
+	return func(c *config) {
+		c.keys = append(c.keys, key{kid: kid, value: k})
+	}
+}
+
+// WithJwksFile adds the signing keys of a local JSON Web Key Set file.
+// The file is read once by New. Keys of unsupported types and keys not
+// meant for signatures or for other algorithms are skipped.
+func WithJwksFile(name string) option {
+	return func(c *config) {
+		c.jwksFiles = append(c.jwksFiles, name)
+	}
+}
+
+// WithIssuer requires the `iss` claim to be one of the given issuers.
+func WithIssuer(issuers ...string) option {
+	return func(c *config) {
+		c.issuers = append(c.issuers, issuers...)
+	}
+}
+
+// WithAudience requires the `aud` claim to contain one of the given
+// audiences.
+func WithAudience(audiences ...string) option {
+	return func(c *config) {
+		c.audiences = append(c.audiences, audiences...)
+	}
+}
+
+// WithLeeway tolerates clock skew of up to d when checking `exp` and
+// `nbf`. Default is no leeway.
+func WithLeeway(d time.Duration) option {
+	return func(c *config) {
+		c.leeway = d
+	}
+}
+
+// WithPolicy applies policy to the procedures matching pattern, a
+// path.Match glob over the full procedure name such as
+// `/acme.user.v1.UserService/*`. Patterns are tried in the order they are
+// added and the first match wins.
+func WithPolicy(pattern string, policy Policy) option {
+	return func(c *config) {
+		c.rules = append(c.rules, rule{pattern: pattern, policy: policy})
+	}
+}
+
+// WithDefaultPolicy sets the policy of procedures no pattern matches.
+// Default is a policy requiring a valid token.
+func WithDefaultPolicy(policy Policy) option {
+	return func(c *config) {
+		c.fallback = policy
+	}
+}
+
+type interceptor struct {
+	verifier
+	rules    []rule
+	fallback Policy
+}
+
+// New creates an interceptor authenticating incoming Connect requests by
+// the bearer JWT of their `Authorization` header. Tokens signed with
+// HS256, RS256 or ES256 are verified against the configured keys and
+// their `exp`, `nbf`, `iss` and `aud` claims are checked. The policy of
+// the procedure then decides whether the request may go on, and the
+// claims are available to handlers through ClaimsFromContext.
+//
+// Missing or invalid tokens fail with CodeUnauthenticated and tokens
+// rejected by a policy with CodePermissionDenied. Outgoing client calls
+// pass through untouched.
+func New(opts ...option) (connect.Interceptor, error) {
+	config := config{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	for _, r := range config.rules {
+		if _, err := path.Match(r.pattern, ""); err != nil {
+			return nil, fmt.Errorf("authint: policy pattern %q: %w", r.pattern, err)
+		}
+	}
+
+	keys := slices.Clone(config.keys)
+	for _, name := range config.jwksFiles {
+		jwks, err := loadJwksFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("authint: %w", err)
+		}
+		keys = append(keys, jwks...)
+	}
+	for index := range keys {
+		if err := keys[index].init(); err != nil {
+			return nil, fmt.Errorf("authint: %w", err)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("authint: no verification keys configured")
+	}
+
+	return &interceptor{
+		verifier: verifier{
+			keys:      keys,
+			issuers:   config.issuers,
+			audiences: config.audiences,
+			leeway:    config.leeway,
+		},
+		rules:    config.rules,
+		fallback: config.fallback,
+	}, nil
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx, err := i.authenticate(ctx, req.Spec().Procedure, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.Spec().Procedure, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *interceptor) authenticate(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+	policy := i.fallback
+	for _, r := range i.rules {
+		if ok, _ := path.Match(r.pattern, procedure); ok {
+			policy = r.policy
+			break
+		}
+	}
+
+	token, found, err := bearerToken(header)
+	if err != nil {
+		return ctx, unauthenticated(err)
+	}
+	if !found {
+		if policy.Anonymous {
+			return ctx, nil
+		}
+		return ctx, unauthenticated(errors.New("missing bearer token"))
+	}
+
+	claims, err := i.verify(token)
+	if err != nil {
+		return ctx, unauthenticated(err)
+	}
+
+	granted := claims.Scopes()
+	for _, scope := range policy.Scopes {
+		if !slices.Contains(granted, scope) {
+			return ctx, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("missing scope %q", scope))
+		}
+	}
+	if policy.Authorize != nil && !policy.Authorize(ctx, claims) {
+		return ctx, connect.NewError(connect.CodePermissionDenied, errors.New("access denied"))
+	}
+	return context.WithValue(ctx, _CTXKEY_CLAIMS, claims), nil
+}
+
+// bearerToken extracts the token of an `Authorization: Bearer` header.
+// Other authorization schemes are reported as missing tokens.
+func bearerToken(header http.Header) (string, bool, error) {
+	authorization := header.Get("Authorization")
+	if authorization == "" {
+		return "", false, nil
+	}
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false, nil
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return "", false, errors.New("empty bearer token")
+	}
+	return token, true, nil
+}
+
+func unauthenticated(err error) error {
+	connectErr := connect.NewError(connect.CodeUnauthenticated, err)
+	connectErr.Meta().Set(HEADER_WWW_AUTHENTICATE, "Bearer")
+	return connectErr
+}
@@ -0,0 +1,273 @@
+package authint_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu/mizuconnect/interceptor/authint"
+)
+
+var _HMAC_SECRET = []byte("mizu-secret")
+
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(value any) string {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input)) // nolint: errcheck
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"sub":   "user-1",
+		"iss":   "https://issuer.mizu",
+		"aud":   []string{"mizu"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "profile:read",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func newClient(t *testing.T, interceptor connect.Interceptor) func(procedure, token string) (string, error) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for _, procedure := range []string{"/test.v1.UserService/GetUser", "/test.v1.UserService/DeleteUser", "/test.v1.PublicService/Ping"} {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+			func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[structpb.Value], error) {
+				claims, ok := authint.ClaimsFromContext(ctx)
+				if !ok {
+					return connect.NewResponse(structpb.NewStringValue("anonymous")), nil
+				}
+				return connect.NewResponse(structpb.NewStringValue(claims.Subject())), nil
+			},
+			connect.WithInterceptors(interceptor),
+		))
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return func(procedure, token string) (string, error) {
+		client := connect.NewClient[emptypb.Empty, structpb.Value](server.Client(), server.URL+procedure)
+		req := connect.NewRequest(&emptypb.Empty{})
+		if token != "" {
+			req.Header().Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.CallUnary(context.Background(), req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Msg.GetStringValue(), nil
+	}
+}
+
+func TestAuthint_New(t *testing.T) {
+	interceptor, err := authint.New(
+		authint.WithKey("", _HMAC_SECRET),
+		authint.WithIssuer("https://issuer.mizu"),
+		authint.WithAudience("mizu"),
+		authint.WithPolicy("/test.v1.PublicService/*", authint.Policy{Anonymous: true}),
+		authint.WithPolicy("/test.v1.UserService/Delete*", authint.Policy{
+			Authorize: func(_ context.Context, claims authint.Claims) bool { return claims.Subject() == "admin" },
+		}),
+		authint.WithDefaultPolicy(authint.Policy{Scopes: []string{"profile:read"}}),
+	)
+	require.NoError(t, err)
+	call := newClient(t, interceptor)
+
+	tests := []struct {
+		name      string
+		procedure string
+		token     string
+		want      string
+		code      connect.Code
+	}{
+		{
+			name:      "valid",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(nil)),
+			want:      "user-1",
+		},
+		{
+			name:      "missing token",
+			procedure: "/test.v1.UserService/GetUser",
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "anonymous",
+			procedure: "/test.v1.PublicService/Ping",
+			want:      "anonymous",
+		},
+		{
+			name:      "anonymous with invalid token",
+			procedure: "/test.v1.PublicService/Ping",
+			token:     sign(t, "HS256", "", []byte("other"), claims(nil)),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "expired",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "no expiry",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"exp": nil})),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "not yet valid",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"nbf": time.Now().Add(time.Minute).Unix()})),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "wrong issuer",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"iss": "https://evil"})),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "wrong audience",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"aud": "other"})),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "algorithm none",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "none", "", []byte{}, claims(nil)),
+			code:      connect.CodeUnauthenticated,
+		},
+		{
+			name:      "missing scope",
+			procedure: "/test.v1.UserService/GetUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"scope": "profile:write"})),
+			code:      connect.CodePermissionDenied,
+		},
+		{
+			name:      "denied by authorize",
+			procedure: "/test.v1.UserService/DeleteUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(nil)),
+			code:      connect.CodePermissionDenied,
+		},
+		{
+			name:      "allowed by authorize",
+			procedure: "/test.v1.UserService/DeleteUser",
+			token:     sign(t, "HS256", "", _HMAC_SECRET, claims(map[string]any{"sub": "admin", "scope": nil})),
+			want:      "admin",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := call(tc.procedure, tc.token)
+			if tc.code != 0 {
+				var connectErr *connect.Error
+				require.True(t, errors.As(err, &connectErr), "got %v", err)
+				assert.Equal(t, tc.code, connectErr.Code())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAuthint_WithJwksFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encode := func(value []byte) string { return base64.RawURLEncoding.EncodeToString(value) }
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	require.NoError(t, err)
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig",
+			"n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecPoint[1:33]), "y": encode(ecPoint[33:])},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AQAB"},
+	}})
+	require.NoError(t, err)
+	name := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(name, jwks, 0o600))
+
+	interceptor, err := authint.New(authint.WithJwksFile(name))
+	require.NoError(t, err)
+	call := newClient(t, interceptor)
+
+	got, err := call("/test.v1.UserService/GetUser", sign(t, "RS256", "rsa", rsaKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got)
+
+	got, err = call("/test.v1.UserService/GetUser", sign(t, "ES256", "ec", ecKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got)
+
+	_, err = call("/test.v1.UserService/GetUser", sign(t, "HS256", "rsa", _HMAC_SECRET, claims(nil)))
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = authint.New()
+	assert.Error(t, err)
+	_, err = authint.New(authint.WithKey("", []byte("k")), authint.WithPolicy("[", authint.Policy{}))
+	assert.Error(t, err)
+	_, err = authint.New(authint.WithJwksFile(filepath.Join(t.TempDir(), "missing.json")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package authint
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	_ALG_HS256 = "HS256"
+	_ALG_RS256 = "RS256"
+	_ALG_ES256 = "ES256"
+)
+
+type key struct {
+	kid   string
+	alg   string
+	value any
+}
+
+// init derives the algorithm of the key from its type and rejects keys
+// that cannot verify it.
+func (k *key) init() error {
+	alg := ""
+	switch value := k.value.(type) {
+	case []byte:
+		if len(value) == 0 {
+			return fmt.Errorf("key %q: empty HMAC secret", k.kid)
+		}
+		alg = _ALG_HS256
+	case *rsa.PublicKey:
+		if value == nil || value.N == nil {
+			return fmt.Errorf("key %q: nil RSA key", k.kid)
+		}
+		alg = _ALG_RS256
+	case *ecdsa.PublicKey:
+		if value == nil || value.Curve != elliptic.P256() {
+			return fmt.Errorf("key %q: ES256 requires a P-256 key", k.kid)
+		}
+		alg = _ALG_ES256
+	default:
+		return fmt.Errorf("key %q: unsupported key type %T", k.kid, k.value)
+	}
+	if k.alg != "" && k.alg != alg {
+		return fmt.Errorf("key %q: algorithm %s does not match key type %T", k.kid, k.alg, k.value)
+	}
+	k.alg = alg
+	return nil
+}
+
+func (k *key) verify(input, signature []byte) bool {
+	digest := sha256.Sum256(input)
+	switch value := k.value.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, value)
+		mac.Write(input) // nolint: errcheck
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(value, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(value, digest[:], r, s)
+	}
+	return false
+}
+
+type verifier struct {
+	keys      []key
+	issuers   []string
+	audiences []string
+	leeway    time.Duration
+}
+
+// verify checks the signature and the registered claims of a compact
+// JWS and returns its claims.
+func (v *verifier) verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical header %q", header.Crit[0])
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	candidates := v.candidates(header.Kid)
+	input := []byte(parts[0] + "." + parts[1])
+	if !slices.ContainsFunc(candidates, func(k key) bool {
+		return k.alg == header.Alg && k.verify(input, signature)
+	}) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		nbf, ok := nbf.(float64)
+		if !ok {
+			return nil, errors.New("malformed nbf claim")
+		}
+		if now.Add(v.leeway).Before(time.Unix(int64(nbf), 0)) {
+			return nil, errors.New("token not valid yet")
+		}
+	}
+	if len(v.issuers) > 0 && !slices.Contains(v.issuers, claims.Issuer()) {
+		return nil, errors.New("untrusted token issuer")
+	}
+	if len(v.audiences) > 0 && !slices.ContainsFunc(claims.Audience(), func(aud string) bool {
+		return slices.Contains(v.audiences, aud)
+	}) {
+		return nil, errors.New("token audience mismatch")
+	}
+	return claims, nil
+}
+
+// candidates returns the keys of kid, falling back to the keys without
+// an id.
+func (v *verifier) candidates(kid string) []key {
+	if kid != "" {
+		var keys []key
+		for _, k := range v.keys {
+			if k.kid == kid {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) > 0 {
+			return keys
+		}
+	}
+	var keys []key
+	for _, k := range v.keys {
+		if k.kid == "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func loadJwksFile(name string) ([]key, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", name, err)
+	}
+
+	keys := make([]key, 0, len(jwks.Keys))
+	for _, item := range jwks.Keys {
+		if item.Use != "" && item.Use != "sig" {
+			continue
+		}
+		if item.Alg != "" && !slices.Contains([]string{_ALG_HS256, _ALG_RS256, _ALG_ES256}, item.Alg) {
+			continue
+		}
+		value, err := item.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS %s: key %q: %w", name, item.Kid, err)
+		}
+		if value == nil {
+			continue
+		}
+		keys = append(keys, key{kid: item.Kid, alg: item.Alg, value: value})
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material of the JWK. It returns nil for key
+// types and curves that cannot verify a supported algorithm.
+func (j jwk) publicKey() (any, error) {
+	decode := func(field, value string) ([]byte, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, fmt.Errorf("malformed %s", field)
+		}
+		return data, nil
+	}
+
+	switch j.Kty {
+	case "oct":
+		return decode("k", j.K)
+	case "RSA":
+		n, err := decode("n", j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", j.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent out of range")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decode("x", j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", j.Y)
+		if err != nil {
+			return nil, err
+		}
+		point := append([]byte{4}, append(make([]byte, 32-min(len(x), 32)), x...)...)
+		point = append(point, append(make([]byte, 32-min(len(y), 32)), y...)...)
+		value, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,388 @@
+package cacheint_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu/mizuconnect/interceptor/cacheint"
+)
+
+func TestCacheint_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("entry limit evicts least recently used", func(t *testing.T) {
+		store := cacheint.NewMemoryStore(2, 0)
+		require.NoError(t, store.Set(ctx, "a", []byte("1"), time.Hour))
+		require.NoError(t, store.Set(ctx, "b", []byte("2"), time.Hour))
+		_, ok, _ := store.Get(ctx, "a")
+		require.True(t, ok)
+		require.NoError(t, store.Set(ctx, "c", []byte("3"), time.Hour))
+
+		_, ok, _ = store.Get(ctx, "b")
+		assert.False(t, ok)
+		_, ok, _ = store.Get(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, 2, store.Len())
+	})
+
+	t.Run("byte limit", func(t *testing.T) {
+		store := cacheint.NewMemoryStore(0, 10)
+		require.NoError(t, store.Set(ctx, "a", []byte("12345"), time.Hour))
+		require.NoError(t, store.Set(ctx, "b", []byte("12345"), time.Hour))
+		assert.Equal(t, 1, store.Len())
+		require.NoError(t, store.Set(ctx, "huge", []byte(strings.Repeat("x", 11)), time.Hour))
+		_, ok, _ := store.Get(ctx, "huge")
+		assert.False(t, ok, "values over the byte limit are not stored")
+		_, ok, _ = store.Get(ctx, "b")
+		assert.True(t, ok)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		store := cacheint.NewMemoryStore(0, 0)
+		require.NoError(t, store.Set(ctx, "short", []byte("1"), time.Millisecond))
+		require.NoError(t, store.Set(ctx, "long", []byte("2"), time.Hour))
+		time.Sleep(5 * time.Millisecond)
+
+		_, ok, _ := store.Get(ctx, "long")
+		assert.True(t, ok)
+		assert.Equal(t, 1, store.Len(), "expired entries are evicted on access")
+
+		require.NoError(t, store.Delete(ctx, "long"))
+		assert.Equal(t, 0, store.Len())
+	})
+}
+
+func TestCacheint_New(t *testing.T) {
+	var calls atomic.Int32
+	interceptor, cache := cacheint.New(
+		cacheint.WithStore(cacheint.NewMemoryStore(10, 0)),
+		cacheint.WithEntryFunc(func(_ context.Context, ar connect.AnyRequest) cacheint.Entry {
+			name := ar.Any().(*structpb.Value).GetStringValue()
+			return cacheint.Entry{Key: name, Expiry: time.Hour, Tags: []string{"user/" + name}}
+		}),
+	)
+	procedure := "/test.v1.Service/Get"
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+		func(_ context.Context, req *connect.Request[structpb.Value]) (*connect.Response[structpb.Value], error) {
+			calls.Add(1)
+			resp := connect.NewResponse(structpb.NewStringValue("hello " + req.Msg.GetStringValue()))
+			resp.Header().Set("X-Served", "origin")
+			return resp, nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := connect.NewClient[structpb.Value, structpb.Value](server.Client(), server.URL+procedure)
+	call := func(name string) *connect.Response[structpb.Value] {
+		resp, err := client.CallUnary(context.Background(), connect.NewRequest(structpb.NewStringValue(name)))
+		require.NoError(t, err)
+		return resp
+	}
+
+	first := call("mizu")
+	second := call("mizu")
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, first.Msg.GetStringValue(), second.Msg.GetStringValue())
+	assert.Equal(t, "origin", second.Header().Get("X-Served"))
+
+	assert.Equal(t, "hello other", call("other").Msg.GetStringValue())
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, cacheint.Stats{Hits: 1, Misses: 2}, cache.Stats())
+
+	ctx := context.Background()
+	require.NoError(t, cache.Invalidate(ctx, "mizu"))
+	call("mizu")
+	call("other")
+	assert.Equal(t, int32(3), calls.Load(), "only the invalidated key is fetched again")
+
+	require.NoError(t, cache.InvalidateTag(ctx, "user/other"))
+	call("mizu")
+	call("other")
+	assert.Equal(t, int32(4), calls.Load(), "only the tagged entry is fetched again")
+
+	require.NoError(t, cache.Purge(ctx))
+	call("mizu")
+	call("other")
+	assert.Equal(t, int32(6), calls.Load())
+	assert.Equal(t, cacheint.Stats{Hits: 3, Misses: 6}, cache.Stats())
+}
+
+func TestCacheint_WithSingleFlight(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	interceptor, cache := cacheint.New(
+		cacheint.WithSingleFlight(true),
+		cacheint.WithKeyFunc(func(context.Context, connect.AnyRequest) (any, time.Duration) {
+			return "shared", time.Hour
+		}),
+	)
+	next := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		calls.Add(1)
+		<-release
+		return connect.NewResponse(structpb.NewStringValue("slow")), nil
+	})
+
+	results := make(chan string, 3)
+	for range 3 {
+		go func() {
+			resp, err := next(context.Background(), connect.NewRequest(structpb.NewNullValue()))
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			results <- resp.Any().(*structpb.Value).GetStringValue()
+		}()
+	}
+	require.Eventually(t, func() bool { return cache.Stats().Misses == 3 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond) // let the followers join the in-flight call
+	close(release)
+	for range 3 {
+		assert.Equal(t, "slow", <-results)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, uint64(2), cache.Stats().Coalesced)
+}
+
+func TestCacheint_StaleWindows(t *testing.T) {
+	var version atomic.Int32
+	var failure atomic.Pointer[connect.Error]
+	interceptor, _ := cacheint.New(
+		cacheint.WithJitterFunc(func(expiry time.Duration) time.Duration { return expiry }),
+		cacheint.WithEntryFunc(func(_ context.Context, ar connect.AnyRequest) cacheint.Entry {
+			entry := cacheint.Entry{Key: ar.Any().(*structpb.Value).GetStringValue(), Expiry: 20 * time.Millisecond}
+			switch entry.Key {
+			case "revalidate":
+				entry.StaleWhileRevalidate = time.Hour
+			case "if-error":
+				entry.StaleIfError = time.Hour
+			}
+			return entry
+		}),
+	)
+	next := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		if err := failure.Load(); err != nil {
+			return nil, err
+		}
+		return connect.NewResponse(structpb.NewNumberValue(float64(version.Add(1)))), nil
+	})
+	call := func(key string) (float64, error) {
+		resp, err := next(context.Background(), connect.NewRequest(structpb.NewStringValue(key)))
+		if err != nil {
+			return 0, err
+		}
+		return resp.Any().(*structpb.Value).GetNumberValue(), nil
+	}
+
+	got, err := call("revalidate")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), got)
+	time.Sleep(30 * time.Millisecond)
+
+	got, err = call("revalidate")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), got, "the stale response is served at once")
+	require.Eventually(t, func() bool {
+		got, err := call("revalidate")
+		return err == nil && got == 2
+	}, time.Second, 5*time.Millisecond, "a background call refreshes the entry")
+
+	got, err = call("if-error")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), got)
+	time.Sleep(30 * time.Millisecond)
+
+	failure.Store(connect.NewError(connect.CodeUnavailable, nil))
+	got, err = call("if-error")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), got, "the stale response replaces an unavailable upstream")
+
+	failure.Store(connect.NewError(connect.CodeNotFound, nil))
+	_, err = call("if-error")
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	_, err = call("plain")
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestCacheint_NewProtoEntryFunc(t *testing.T) {
+	const (
+		get   = "/test.v1.Service/Get"
+		list  = "/test.v1.Service/List"
+		put   = "/test.v1.Service/Put"
+		plain = "/test.v1.Service/Plain"
+	)
+	var calls atomic.Int32
+	interceptor, _ := cacheint.New(cacheint.WithEntryFunc(cacheint.NewProtoEntryFunc(
+		cacheint.WithVaryHeaders("accept-language"),
+		cacheint.WithProcedureExpiry(list, time.Hour),
+	)))
+	handle := func(context.Context, *connect.Request[structpb.Value]) (*connect.Response[structpb.Value], error) {
+		calls.Add(1)
+		return connect.NewResponse(structpb.NewNumberValue(float64(calls.Load()))), nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(get, connect.NewUnaryHandler(get, handle,
+		connect.WithInterceptors(interceptor), connect.WithIdempotency(connect.IdempotencyNoSideEffects)))
+	mux.Handle(list, connect.NewUnaryHandler(list, handle, connect.WithInterceptors(interceptor)))
+	mux.Handle(put, connect.NewUnaryHandler(put, handle, connect.WithInterceptors(interceptor)))
+	plainInterceptor, _ := cacheint.New()
+	mux.Handle(plain, connect.NewUnaryHandler(plain, handle,
+		connect.WithInterceptors(plainInterceptor), connect.WithIdempotency(connect.IdempotencyNoSideEffects)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	call := func(procedure string, msg *structpb.Value, language string, token ...string) float64 {
+		client := connect.NewClient[structpb.Value, structpb.Value](server.Client(), server.URL+procedure)
+		req := connect.NewRequest(msg)
+		req.Header().Set("Accept-Language", language)
+		for _, token := range token {
+			req.Header().Set("Authorization", "Bearer "+token)
+		}
+		req.Header().Set("X-Request-Id", time.Now().String())
+		resp, err := client.CallUnary(context.Background(), req)
+		require.NoError(t, err)
+		return resp.Msg.GetNumberValue()
+	}
+	message := func() *structpb.Value {
+		value, err := structpb.NewValue(map[string]any{"b": 2, "a": 1, "c": "x"})
+		require.NoError(t, err)
+		return value
+	}
+
+	first := call(get, message(), "en")
+	assert.Equal(t, first, call(get, message(), "en"), "equal messages share a key, other headers are ignored")
+	assert.NotEqual(t, first, call(get, message(), "fr"), "vary headers are part of the key")
+	assert.NotEqual(t, first, call(get, structpb.NewStringValue("other"), "en"))
+
+	alice := call(get, message(), "en", "alice")
+	assert.NotEqual(t, first, alice, "callers do not share keys")
+	assert.Equal(t, alice, call(get, message(), "en", "alice"))
+	assert.NotEqual(t, alice, call(get, message(), "en", "bob"))
+
+	listed := call(list, message(), "en")
+	assert.Equal(t, listed, call(list, message(), "en"), "procedure expiry caches any procedure")
+	assert.NotEqual(t, first, listed, "procedures do not share keys")
+
+	assert.NotEqual(t, call(put, message(), "en"), call(put, message(), "en"), "other procedures are not cached")
+	assert.NotEqual(t, call(plain, message(), "en"), call(plain, message(), "en"), "New caches nothing by default")
+}
+
+func TestCacheint_WithStreamEntryFunc(t *testing.T) {
+	const procedure = "/test.v1.Service/Export"
+	type call func(name string) ([]string, http.Header, error)
+	setup := func(t *testing.T, singleFlight bool, maxBytes int, release <-chan struct{}) (call, *atomic.Int32, *cacheint.Cache) {
+		var calls atomic.Int32
+		interceptor, cache := cacheint.New(
+			cacheint.WithSingleFlight(singleFlight),
+			cacheint.WithStreamMaxBytes(maxBytes),
+			cacheint.WithStreamEntryFunc(func(_ context.Context, _ connect.StreamingHandlerConn, msg any) cacheint.Entry {
+				return cacheint.Entry{Key: msg.(*structpb.Value).GetStringValue(), Expiry: time.Hour}
+			}),
+		)
+		mux := http.NewServeMux()
+		mux.Handle(procedure, connect.NewServerStreamHandler(procedure,
+			func(_ context.Context, req *connect.Request[structpb.Value], stream *connect.ServerStream[structpb.Value]) error {
+				calls.Add(1)
+				if req.Msg.GetStringValue() == "slow" {
+					<-release
+				}
+				stream.ResponseHeader().Set("X-Served", "origin")
+				stream.ResponseTrailer().Set("X-Count", "3")
+				for n := range 3 {
+					if err := stream.Send(structpb.NewStringValue(fmt.Sprintf("%s/%d", req.Msg.GetStringValue(), n))); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			connect.WithInterceptors(interceptor),
+		))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		client := connect.NewClient[structpb.Value, structpb.Value](server.Client(), server.URL+procedure)
+		return func(name string) ([]string, http.Header, error) {
+			stream, err := client.CallServerStream(context.Background(), connect.NewRequest(structpb.NewStringValue(name)))
+			if err != nil {
+				return nil, nil, err
+			}
+			defer stream.Close() // nolint: errcheck
+			var got []string
+			for stream.Receive() {
+				got = append(got, stream.Msg().GetStringValue())
+			}
+			header := stream.ResponseHeader().Clone()
+			header.Set("X-Count", stream.ResponseTrailer().Get("X-Count"))
+			return got, header, stream.Err()
+		}, &calls, cache
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		call, calls, cache := setup(t, false, cacheint.DEFAULT_STREAM_MAX_BYTES, nil)
+		for range 3 {
+			got, header, err := call("mizu")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"mizu/0", "mizu/1", "mizu/2"}, got)
+			assert.Equal(t, "origin", header.Get("X-Served"))
+			assert.Equal(t, "3", header.Get("X-Count"))
+		}
+		assert.Equal(t, int32(2), calls.Load(), "the first stream learns the request type")
+		assert.Equal(t, cacheint.Stats{Hits: 1, Misses: 1}, cache.Stats())
+
+		require.NoError(t, cache.Invalidate(context.Background(), "mizu"))
+		_, _, err := call("mizu")
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("size cap", func(t *testing.T) {
+		call, calls, _ := setup(t, false, 10, nil)
+		for range 3 {
+			got, _, err := call("mizu")
+			require.NoError(t, err)
+			assert.Len(t, got, 3)
+		}
+		assert.Equal(t, int32(3), calls.Load(), "streams over the cap are not cached")
+	})
+
+	t.Run("single flight", func(t *testing.T) {
+		release := make(chan struct{})
+		call, calls, cache := setup(t, true, cacheint.DEFAULT_STREAM_MAX_BYTES, release)
+		_, _, err := call("warmup")
+		require.NoError(t, err)
+
+		results := make(chan []string, 3)
+		for range 3 {
+			go func() {
+				got, _, err := call("slow")
+				if err != nil {
+					results <- []string{err.Error()}
+					return
+				}
+				results <- got
+			}()
+		}
+		require.Eventually(t, func() bool { return cache.Stats().Misses == 3 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond) // let the followers join the in-flight stream
+		close(release)
+		for range 3 {
+			assert.Equal(t, []string{"slow/0", "slow/1", "slow/2"}, <-results)
+		}
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, uint64(2), cache.Stats().Coalesced)
+	})
+}
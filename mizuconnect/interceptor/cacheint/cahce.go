@@ -2,62 +2,94 @@ package cacheint
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand/v2"
+	"net/http"
 	"reflect"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
-	"unsafe"
 
 	"connectrpc.com/connect"
 	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
 )
 
-// INFO: init check the type structure of connect.Response[T] to make
-// sure the clone() method works as expected.
-func init() {
-	st := connect.Response[struct{}]{}
-	var _ connect.AnyResponse = &st
-
-	fieldHeader := reflect.ValueOf(st).Type().Field(1)
-	if fieldHeader.Name != "header" || fieldHeader.Type.Name() != "Header" {
-		panic("Breaking change in current version of Connect RPC, header field not found")
-	}
-
-	fieldTrailer := reflect.ValueOf(st).Type().Field(2)
-	if fieldTrailer.Name != "trailer" || fieldTrailer.Type.Name() != "Header" {
-		panic("Breaking change in current version of Connect RPC, trailer field not found")
-	}
-}
+// Store keys of the versions entries are checked against. Bumping a
+// version invalidates every entry recorded with the previous one, on all
+// processes sharing the store.
+const (
+	_VERSION_KEY_GENERATION = "cacheint/generation"
+	_VERSION_KEY_TAG_PREFIX = "cacheint/tag/"
+	_VERSION_EXPIRY         = 30 * 24 * time.Hour
+)
 
 type interceptor struct {
-	cache
 	singleflight.Group
+	stats
 
+	store              Store
 	enableSingleFlight bool
-	keyFunc            func(context.Context, connect.AnyRequest) (any, time.Duration)
-	cleanupArbiter     func(context.Context, connect.AnyResponse) bool
+	entryFunc          func(context.Context, connect.AnyRequest) Entry
+	staleIfErrorCodes  []connect.Code
+	jitterFunc         func(expiry time.Duration) time.Duration
+	streamEntryFunc    func(context.Context, connect.StreamingHandlerConn, any) Entry
+	streamMaxBytes     int
+
+	// responseTypes maps procedures to the *connect.Response[T] type their
+	// cached entries decode into.
+	responseTypes sync.Map
+	// requestTypes and messageTypes map server-streaming procedures to the
+	// pointer types of their request and response messages.
+	requestTypes sync.Map
+	messageTypes sync.Map
+}
+
+// Entry describes how the response to a request is cached.
+type Entry struct {
+	// Key identifies the response. Requests with equal keys share it.
+	Key any
+	// Expiry is how long the response is cached. Zero disables caching.
+	Expiry time.Duration
+	// Tags group entries for Cache.InvalidateTag.
+	Tags []string
+	// StaleWhileRevalidate is how long after Expiry the stale response is
+	// still served, while a single background call refreshes it.
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long after Expiry the stale response is served
+	// in place of an error with one of the codes set by
+	// WithStaleIfErrorCodes.
+	StaleIfError time.Duration
 }
 
 type option func(*config)
 
 type config struct {
+	store              Store
 	enableSingleFlight bool
-	keyFunc            func(context.Context, connect.AnyRequest) (any, time.Duration)
-	cleanupArbiter     func(context.Context, connect.AnyResponse) bool
+	entryFunc          func(context.Context, connect.AnyRequest) Entry
+	staleIfErrorCodes  []connect.Code
+	streamEntryFunc    func(context.Context, connect.StreamingHandlerConn, any) Entry
+	streamMaxBytes     int
 
 	jitterFunc func(expiry time.Duration) time.Duration
 }
 
 var defaultConfig = config{
 	enableSingleFlight: false,
-	keyFunc: func(ctx context.Context, ar connect.AnyRequest) (any, time.Duration) {
-		return nil, 0
+	entryFunc: func(ctx context.Context, ar connect.AnyRequest) Entry {
+		return Entry{}
 	},
-	cleanupArbiter: func(ctx context.Context, ar connect.AnyResponse) bool {
-		// nolint:gosec
-		return rand.IntN(1_000) == 0
+	staleIfErrorCodes: []connect.Code{
+		connect.CodeUnknown,
+		connect.CodeInternal,
+		connect.CodeUnavailable,
+		connect.CodeDeadlineExceeded,
+		connect.CodeResourceExhausted,
 	},
+	streamMaxBytes: DEFAULT_STREAM_MAX_BYTES,
 
 	jitterFunc: func(expiry time.Duration) time.Duration {
 		// nolint:gosec
@@ -84,7 +116,42 @@ func WithKeyFunc(f func(context.Context, connect.AnyRequest) (any, time.Duration
 		if f == nil {
 			return
 		}
-		c.keyFunc = f
+		c.entryFunc = func(ctx context.Context, ar connect.AnyRequest) Entry {
+			key, expiry := f(ctx, ar)
+			return Entry{Key: key, Expiry: expiry}
+		}
+	}
+}
+
+// WithEntryFunc is WithKeyFunc for entries that also carry tags and
+// stale windows. It replaces the function set by WithKeyFunc.
+func WithEntryFunc(f func(context.Context, connect.AnyRequest) Entry) option {
+	return func(c *config) {
+		if f == nil {
+			return
+		}
+		c.entryFunc = f
+	}
+}
+
+// WithStreamEntryFunc enables caching of server streams. The function
+// receives the stream and its request message, and returns the entry of
+// the stream like the function of WithEntryFunc does for unary calls.
+// Stale windows do not apply to streams. Default is nil, streams are not
+// cached.
+func WithStreamEntryFunc(f func(ctx context.Context, conn connect.StreamingHandlerConn, msg any) Entry) option {
+	return func(c *config) {
+		c.streamEntryFunc = f
+	}
+}
+
+// WithStreamMaxBytes sets how many bytes of messages a server stream may
+// send and still be cached. Longer streams are sent in full but not
+// cached. Zero or negative does not limit them. Default is
+// DEFAULT_STREAM_MAX_BYTES.
+func WithStreamMaxBytes(n int) option {
+	return func(c *config) {
+		c.streamMaxBytes = n
 	}
 }
 
@@ -102,138 +169,328 @@ func WithJitterFunc(f func(expiry time.Duration) time.Duration) option {
 	}
 }
 
-// WithCleanupArbiter sets a custom function to determine when cache
-// cleanup should occur. The function receives the response and should
-// return true to trigger cleanup of expired entries. Default function
-// triggers cleanup randomly with 0.1% probability (1 in 1000 requests).
+// WithCleanupArbiter used to decide when expired entries were swept.
+//
+// Deprecated: expired entries are evicted by the Store, this option has
+// no effect.
 func WithCleanupArbiter(f func(context.Context, connect.AnyResponse) bool) option {
+	return func(c *config) {}
+}
+
+// WithStaleIfErrorCodes sets the error codes a stale response may
+// replace within Entry.StaleIfError. Default is CodeUnknown,
+// CodeInternal, CodeUnavailable, CodeDeadlineExceeded and
+// CodeResourceExhausted.
+func WithStaleIfErrorCodes(codes ...connect.Code) option {
 	return func(c *config) {
-		if f == nil {
+		c.staleIfErrorCodes = codes
+	}
+}
+
+// WithStore sets the store keeping cached responses. Default is a
+// MemoryStore bounded by DEFAULT_MAX_ENTRIES entries and
+// DEFAULT_MAX_BYTES bytes.
+func WithStore(store Store) option {
+	return func(c *config) {
+		if store == nil {
 			return
 		}
-		c.cleanupArbiter = f
+		c.store = store
 	}
 }
 
-// New creates a new cache interceptor with the given options. The
-// interceptor provides response caching for Connect RPC unary calls
-// with support for single-flight deduplication, custom key generation,
-// jittered expiration, and automatic cleanup of expired entries.
-func New(opts ...option) connect.Interceptor {
+// Bounds of the default MemoryStore.
+const (
+	DEFAULT_MAX_ENTRIES       = 10_000
+	DEFAULT_MAX_BYTES   int64 = 64 << 20
+)
+
+// DEFAULT_STREAM_MAX_BYTES is how many bytes of messages a server stream
+// may send and still be cached by default.
+const DEFAULT_STREAM_MAX_BYTES = 1 << 20
+
+// New creates a new cache interceptor with the given options, and the
+// Cache handle to invalidate its entries and read its statistics. The
+// interceptor provides response caching for Connect RPC unary calls,
+// and server streams with WithStreamEntryFunc, with support for
+// single-flight deduplication, custom key generation, jittered
+// expiration, stale windows and pluggable bounded storage.
+//
+// Responses are stored serialised, so only responses whose message is
+// a proto.Message are cached. An entry is decoded into the response
+// type of its procedure, which the interceptor learns from the first
+// response of that procedure it sees; until then, entries written by
+// other processes to a shared store count as misses.
+func New(opts ...option) (connect.Interceptor, *Cache) {
 	config := defaultConfig
 	for _, opt := range opts {
 		opt(&config)
 	}
+	if config.store == nil {
+		config.store = NewMemoryStore(DEFAULT_MAX_ENTRIES, DEFAULT_MAX_BYTES)
+	}
 	interceptor := &interceptor{
-		cache:              cache{mp: &sync.Map{}, jitterFunc: config.jitterFunc},
+		store:              config.store,
 		enableSingleFlight: config.enableSingleFlight,
-		keyFunc:            config.keyFunc,
+		entryFunc:          config.entryFunc,
+		staleIfErrorCodes:  config.staleIfErrorCodes,
+		jitterFunc:         config.jitterFunc,
+		streamEntryFunc:    config.streamEntryFunc,
+		streamMaxBytes:     config.streamMaxBytes,
 	}
 
-	return connect.UnaryInterceptorFunc(interceptor.WrapUnary)
+	return interceptor, &Cache{interceptor: interceptor}
 }
 
 func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, ar connect.AnyRequest) (connect.AnyResponse, error) {
-		key, expiry := i.keyFunc(ctx, ar)
-		if expiry == 0 {
+		entry := i.entryFunc(ctx, ar)
+		if entry.Expiry == 0 {
 			return next(ctx, ar)
 		}
 
-		if resp, ok := i.Get(key); ok {
-			return clone(resp), nil
+		key := storeKey(entry.Key)
+		procedure := ar.Spec().Procedure
+		now := time.Now()
+		stale, rec, ok := i.load(ctx, procedure, key)
+		switch {
+		case ok && now.Before(rec.FreshUntil):
+			i.hits.Add(1)
+			return stale, nil
+		case ok && now.Before(rec.StaleWhileRevalidateUntil):
+			i.hits.Add(1)
+			// The refresh outlives the request, and callers arriving while it
+			// runs join it instead of starting another.
+			refreshCtx := context.WithoutCancel(ctx)
+			i.DoChan(key, func() (any, error) {
+				_, data, err := i.call(refreshCtx, ar, next, procedure, key, entry)
+				return data, err
+			})
+			return stale, nil
+		}
+		i.misses.Add(1)
+
+		resp, err := i.fetch(ctx, ar, next, procedure, key, entry)
+		if err != nil && ok && now.Before(rec.StaleIfErrorUntil) &&
+			slices.Contains(i.staleIfErrorCodes, connect.CodeOf(err)) {
+			return stale, nil
 		}
+		return resp, err
+	}
+}
 
-		var resp connect.AnyResponse
+// fetch calls next and caches its response, through single-flight if
+// enabled.
+func (i *interceptor) fetch(
+	ctx context.Context, ar connect.AnyRequest, next connect.UnaryFunc, procedure, key string, entry Entry,
+) (connect.AnyResponse, error) {
+	if !i.enableSingleFlight {
+		resp, _, err := i.call(ctx, ar, next, procedure, key, entry)
+		return resp, err
+	}
+
+	var resp connect.AnyResponse
+	leader := false
+	data, err, _ := i.Do(key, func() (any, error) {
+		var data []byte
 		var err error
+		leader = true
+		resp, data, err = i.call(ctx, ar, next, procedure, key, entry)
+		return data, err
+	})
+	if !leader {
+		i.coalesced.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if leader {
+		return resp, nil
+	}
+	// Coalesced callers decode their own copy of the leader's response.
+	if resp, ok := i.decode(procedure, data.([]byte)); ok {
+		return resp, nil
+	}
+	return next(ctx, ar)
+}
 
-		defer func() {
-			if i.cleanupArbiter(ctx, resp) {
-				for key, val := range i.mp.Range {
-					if e := val.(*entry); e.expiration.Before(time.Now()) {
-						i.mp.Delete(key)
-					}
-				}
-			}
-		}()
+// call calls next and caches its response. It also returns the
+// serialised response, or nil if it was not cached.
+func (i *interceptor) call(
+	ctx context.Context, ar connect.AnyRequest, next connect.UnaryFunc, procedure, key string, entry Entry,
+) (connect.AnyResponse, []byte, error) {
+	// Versions are read before the call, so that an invalidation racing
+	// with it leaves the entry stale.
+	versions, err := i.versions(ctx, entry.Tags)
+	if err != nil {
+		resp, err := next(ctx, ar)
+		return resp, nil, err
+	}
+	resp, err := next(ctx, ar)
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, i.save(ctx, procedure, key, resp, entry, versions), nil
+}
 
-		if !i.enableSingleFlight {
-			resp, err = next(ctx, ar)
-			if err != nil {
-				return resp, err
-			}
-			i.Set(key, resp, expiry)
-			return resp, nil
-		}
+func storeKey(key any) string {
+	return fmt.Sprintf("%T:%v", key, key)
+}
 
-		_, err, _ = i.Do(fmt.Sprintf("%T:%v", key, key), func() (any, error) {
-			resp, err = next(ctx, ar)
-			if err != nil {
-				return resp, err
-			}
-			i.Set(key, resp, expiry)
-			return resp, nil
-		})
+func tagKey(tag string) string {
+	return _VERSION_KEY_TAG_PREFIX + tag
+}
+
+// versions returns the current version of the generation and of every
+// tag, keyed by their store keys, creating the missing ones.
+func (i *interceptor) versions(ctx context.Context, tags []string) (map[string]string, error) {
+	versions := make(map[string]string, len(tags)+1)
+	keys := []string{_VERSION_KEY_GENERATION}
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	for _, key := range keys {
+		data, ok, err := i.store.Get(ctx, key)
 		if err != nil {
-			return clone(resp), err
+			return nil, err
+		}
+		if !ok {
+			if data, err = i.bump(ctx, key); err != nil {
+				return nil, err
+			}
 		}
-		return clone(resp), nil
+		versions[key] = string(data)
 	}
+	return versions, nil
 }
 
-func clone(response connect.AnyResponse) connect.AnyResponse {
-	st := reflect.ValueOf(response).Elem()
-	if st.IsZero() {
-		return response
-	}
+func (i *interceptor) bump(ctx context.Context, key string) ([]byte, error) {
+	// nolint:gosec
+	version := []byte(strconv.FormatUint(rand.Uint64(), 36))
+	return version, i.store.Set(ctx, key, version, _VERSION_EXPIRY)
+}
 
-	newResp := reflect.New(st.Type())
-	newResp.Elem().Set(st)
+// record is the serialised form of a cached response.
+type record struct {
+	Header   http.Header       `json:"header,omitempty"`
+	Trailer  http.Header       `json:"trailer,omitempty"`
+	Message  []byte            `json:"message"`
+	Versions map[string]string `json:"versions,omitempty"`
+
+	FreshUntil                time.Time `json:"freshUntil"`
+	StaleWhileRevalidateUntil time.Time `json:"staleWhileRevalidateUntil"`
+	StaleIfErrorUntil         time.Time `json:"staleIfErrorUntil"`
+}
+
+// load returns the cached response of key, fresh or stale, and its
+// record.
+func (i *interceptor) load(ctx context.Context, procedure, key string) (connect.AnyResponse, record, bool) {
+	var rec record
+	data, ok, err := i.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, rec, false
+	}
+	if _, known := i.responseTypes.Load(procedure); !known {
+		return nil, rec, false
+	}
 
-	fieldHeader := newResp.Elem().Field(1)
-	settableHeader := reflect.NewAt(
-		fieldHeader.Type(),
-		unsafe.Pointer(fieldHeader.UnsafeAddr()), // nolint: gosec
-	).Elem()
-	settableHeader.Set(reflect.ValueOf(response.Header().Clone()))
+	if err := json.Unmarshal(data, &rec); err != nil {
+		_ = i.store.Delete(ctx, key)
+		return nil, rec, false
+	}
+	if !i.current(ctx, key, rec.Versions) {
+		return nil, rec, false
+	}
 
-	fieldTrailer := newResp.Elem().Field(2)
-	settableTrailer := reflect.NewAt(
-		fieldTrailer.Type(),
-		unsafe.Pointer(fieldTrailer.UnsafeAddr()), // nolint: gosec
-	).Elem()
-	settableTrailer.Set(reflect.ValueOf(response.Trailer().Clone()))
+	resp, ok := i.build(procedure, rec)
+	if !ok {
+		// Entries that no longer decode, such as after a schema change, would
+		// otherwise shadow fresh responses until they expire.
+		_ = i.store.Delete(ctx, key)
+	}
+	return resp, rec, ok
+}
 
-	return newResp.Interface().(connect.AnyResponse)
+// current reports whether the versions recorded in the entry of key are
+// still current, deleting the entry if they are not.
+func (i *interceptor) current(ctx context.Context, key string, versions map[string]string) bool {
+	for versionKey, version := range versions {
+		current, ok, err := i.store.Get(ctx, versionKey)
+		if err != nil {
+			return false
+		}
+		if !ok || string(current) != version {
+			_ = i.store.Delete(ctx, key)
+			return false
+		}
+	}
+	return true
 }
 
-type entry struct {
-	expiration time.Time
-	value      connect.AnyResponse
+// save stores resp and returns its serialised form, or nil if it cannot
+// be serialised.
+func (i *interceptor) save(
+	ctx context.Context, procedure, key string, resp connect.AnyResponse, entry Entry,
+	versions map[string]string,
+) []byte {
+	message, ok := resp.Any().(proto.Message)
+	if !ok {
+		return nil
+	}
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	fresh := i.jitterFunc(entry.Expiry)
+	freshUntil := time.Now().Add(fresh)
+	data, err := json.Marshal(record{
+		Header:   resp.Header(),
+		Trailer:  resp.Trailer(),
+		Message:  payload,
+		Versions: versions,
+
+		FreshUntil:                freshUntil,
+		StaleWhileRevalidateUntil: freshUntil.Add(entry.StaleWhileRevalidate),
+		StaleIfErrorUntil:         freshUntil.Add(entry.StaleIfError),
+	})
+	if err != nil {
+		return nil
+	}
+
+	i.responseTypes.Store(procedure, reflect.TypeOf(resp))
+	_ = i.store.Set(ctx, key, data, fresh+max(entry.StaleWhileRevalidate, entry.StaleIfError))
+	return data
 }
 
-type cache struct {
-	mp         *sync.Map
-	jitterFunc func(expiry time.Duration) time.Duration
+func (i *interceptor) decode(procedure string, data []byte) (connect.AnyResponse, bool) {
+	var rec record
+	if data == nil || json.Unmarshal(data, &rec) != nil {
+		return nil, false
+	}
+	return i.build(procedure, rec)
 }
 
-func (c cache) Get(key any) (connect.AnyResponse, bool) {
-	v, ok := c.mp.Load(key)
+// build decodes rec into a new response of the type of procedure.
+func (i *interceptor) build(procedure string, rec record) (connect.AnyResponse, bool) {
+	value, ok := i.responseTypes.Load(procedure)
 	if !ok {
 		return nil, false
 	}
 
-	e := v.(*entry)
-	if e.expiration.Before(time.Now()) {
-		c.mp.Delete(key)
+	typ := value.(reflect.Type)
+	resp := reflect.New(typ.Elem())
+	msg := resp.Elem().FieldByName("Msg")
+	msg.Set(reflect.New(msg.Type().Elem()))
+	if err := proto.Unmarshal(rec.Message, msg.Interface().(proto.Message)); err != nil {
 		return nil, false
 	}
-	return e.value, true
-}
 
-func (c cache) Set(key any, value connect.AnyResponse, expiry time.Duration) {
-	c.mp.Store(key, &entry{
-		value:      value,
-		expiration: time.Now().Add(c.jitterFunc(expiry)),
-	})
+	ret := resp.Interface().(connect.AnyResponse)
+	for k, v := range rec.Header {
+		ret.Header()[k] = v
+	}
+	for k, v := range rec.Trailer {
+		ret.Trailer()[k] = v
+	}
+	return ret, true
 }
@@ -0,0 +1,70 @@
+package cacheint
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+type stats struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// Stats is a snapshot of the counters of a Cache since it was created.
+type Stats struct {
+	// Hits counts requests served from the store.
+	Hits uint64
+	// Misses counts requests not found in the store.
+	Misses uint64
+	// Coalesced counts misses served by another in-flight request through
+	// single-flight deduplication.
+	Coalesced uint64
+	// Evictions counts entries the store evicted for its bounds or their
+	// expiry. It stays zero for stores that do not report evictions.
+	Evictions uint64
+}
+
+// Cache is the handle of a cache interceptor created by New.
+type Cache struct {
+	interceptor *interceptor
+}
+
+// Invalidate removes the entry of key, as returned by the key function.
+func (c *Cache) Invalidate(ctx context.Context, key any) error {
+	return c.interceptor.store.Delete(ctx, storeKey(key))
+}
+
+// InvalidateTag invalidates every entry tagged with tag, including those
+// written by other processes sharing the store. The entries are dropped
+// on their next lookup.
+func (c *Cache) InvalidateTag(ctx context.Context, tag string) error {
+	_, err := c.interceptor.bump(ctx, tagKey(tag))
+	return err
+}
+
+// Purge invalidates every entry, including those written by other
+// processes sharing the store. Stores with a Purge method, such as
+// MemoryStore, are also emptied.
+func (c *Cache) Purge(ctx context.Context) error {
+	var err error
+	if purger, ok := c.interceptor.store.(interface{ Purge(context.Context) error }); ok {
+		err = purger.Purge(ctx)
+	}
+	_, bumpErr := c.interceptor.bump(ctx, _VERSION_KEY_GENERATION)
+	return errors.Join(err, bumpErr)
+}
+
+// Stats returns the current counters.
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Hits:      c.interceptor.hits.Load(),
+		Misses:    c.interceptor.misses.Load(),
+		Coalesced: c.interceptor.coalesced.Load(),
+	}
+	if counter, ok := c.interceptor.store.(interface{ Evictions() uint64 }); ok {
+		stats.Evictions = counter.Evictions()
+	}
+	return stats
+}
@@ -0,0 +1,138 @@
+package cacheint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DEFAULT_NO_SIDE_EFFECTS_EXPIRY is how long NewProtoEntryFunc caches
+// procedures declared `idempotency_level = NO_SIDE_EFFECTS` by default.
+const DEFAULT_NO_SIDE_EFFECTS_EXPIRY = time.Minute
+
+type keyOption func(*keyConfig)
+
+type keyConfig struct {
+	vary                []string
+	expiries            map[string]time.Duration
+	noSideEffectsExpiry time.Duration
+}
+
+// WithVaryHeaders adds request headers whose values are part of the key,
+// such as `Accept-Language` for localised responses, to the caller
+// identity headers `Authorization` and `Cookie` that always are. Other
+// headers are ignored.
+func WithVaryHeaders(headers ...string) keyOption {
+	return func(c *keyConfig) {
+		for _, header := range headers {
+			c.vary = append(c.vary, http.CanonicalHeaderKey(header))
+		}
+	}
+}
+
+// WithProcedureExpiry caches procedure, a full procedure name such as
+// `/acme.user.v1.UserService/GetUser`, for expiry regardless of its
+// idempotency level. Zero disables caching for the procedure.
+func WithProcedureExpiry(procedure string, expiry time.Duration) keyOption {
+	return func(c *keyConfig) {
+		c.expiries[procedure] = expiry
+	}
+}
+
+// WithNoSideEffectsExpiry sets how long procedures declared
+// `idempotency_level = NO_SIDE_EFFECTS` are cached when no procedure
+// expiry is set for them. Zero disables their automatic caching. Default
+// is DEFAULT_NO_SIDE_EFFECTS_EXPIRY.
+func WithNoSideEffectsExpiry(expiry time.Duration) keyOption {
+	return func(c *keyConfig) {
+		c.noSideEffectsExpiry = expiry
+	}
+}
+
+// NewProtoEntryFunc creates an entry function for WithEntryFunc keying
+// requests by procedure name, the deterministic proto encoding of the
+// request message and the values of the vary headers. Requests whose
+// message is not a proto.Message are not cached.
+//
+// Callers authenticated otherwise than by `Authorization` or `Cookie`,
+// such as by an API key header or mTLS, share entries unless that header
+// is given to WithVaryHeaders or the procedure is not cached.
+func NewProtoEntryFunc(opts ...keyOption) func(context.Context, connect.AnyRequest) Entry {
+	config := newKeyConfig(opts)
+	return func(_ context.Context, ar connect.AnyRequest) Entry {
+		return config.entry(ar.Spec(), ar.Header(), ar.Any())
+	}
+}
+
+// NewProtoStreamEntryFunc is NewProtoEntryFunc for WithStreamEntryFunc,
+// keying server streams by their request message.
+func NewProtoStreamEntryFunc(opts ...keyOption) func(context.Context, connect.StreamingHandlerConn, any) Entry {
+	config := newKeyConfig(opts)
+	return func(_ context.Context, conn connect.StreamingHandlerConn, msg any) Entry {
+		return config.entry(conn.Spec(), conn.RequestHeader(), msg)
+	}
+}
+
+func newKeyConfig(opts []keyOption) keyConfig {
+	config := keyConfig{
+		vary:                []string{"Authorization", "Cookie"},
+		expiries:            make(map[string]time.Duration),
+		noSideEffectsExpiry: DEFAULT_NO_SIDE_EFFECTS_EXPIRY,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+func (c keyConfig) entry(spec connect.Spec, header http.Header, msg any) Entry {
+	expiry, ok := c.expiries[spec.Procedure]
+	if !ok && isNoSideEffects(spec) {
+		expiry = c.noSideEffectsExpiry
+	}
+	if expiry == 0 {
+		return Entry{}
+	}
+
+	message, ok := msg.(proto.Message)
+	if !ok {
+		return Entry{}
+	}
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return Entry{}
+	}
+
+	hash := sha256.New()
+	hash.Write(payload) // nolint: errcheck
+	for _, name := range c.vary {
+		hash.Write([]byte{0})    // nolint: errcheck
+		hash.Write([]byte(name)) // nolint: errcheck
+		for _, value := range header.Values(name) {
+			hash.Write([]byte{1})     // nolint: errcheck
+			hash.Write([]byte(value)) // nolint: errcheck
+		}
+	}
+	return Entry{Key: spec.Procedure + ":" + hex.EncodeToString(hash.Sum(nil)), Expiry: expiry}
+}
+
+// isNoSideEffects reports whether the procedure is declared free of side
+// effects, by its Connect handler options or its method descriptor.
+func isNoSideEffects(spec connect.Spec) bool {
+	if spec.IdempotencyLevel == connect.IdempotencyNoSideEffects {
+		return true
+	}
+	method, ok := spec.Schema.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	return ok && options.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
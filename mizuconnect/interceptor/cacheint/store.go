@@ -0,0 +1,179 @@
+package cacheint
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store keeps serialised responses by key until they expire. Values are
+// opaque byte slices, so implementations may keep them in memory, on disk
+// or in an external cache shared by several replicas.
+type Store interface {
+	// Get returns the value of key, or false if it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for the given duration.
+	Set(ctx context.Context, key string, value []byte, expiry time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store local to the process. It holds at most
+// maxEntries entries and about maxBytes bytes of keys and values,
+// evicting the least recently used entries first. Expired entries are
+// evicted on the next access to the store.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	lru        *list.List
+	items      map[string]*list.Element
+	expiries   expiryHeap
+	evictions  atomic.Uint64
+}
+
+type memoryItem struct {
+	key        string
+	value      []byte
+	expiration time.Time
+	index      int
+}
+
+func (item *memoryItem) size() int64 {
+	return int64(len(item.key) + len(item.value))
+}
+
+// NewMemoryStore creates a MemoryStore bounded by maxEntries entries and
+// maxBytes bytes. A bound that is zero or negative is not enforced.
+func NewMemoryStore(maxEntries int, maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*memoryItem).value, true, nil
+}
+
+// Set implements Store. Values larger than maxBytes are not stored.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, expiry time.Duration) error {
+	now := time.Now()
+	item := &memoryItem{key: key, value: value, expiration: now.Add(expiry)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(now)
+	if element, ok := s.items[key]; ok {
+		s.remove(element)
+	}
+	if expiry <= 0 || s.maxBytes > 0 && item.size() > s.maxBytes {
+		return nil
+	}
+
+	s.items[key] = s.lru.PushFront(item)
+	heap.Push(&s.expiries, item)
+	s.bytes += item.size()
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries || s.maxBytes > 0 && s.bytes > s.maxBytes {
+		s.remove(s.lru.Back())
+		s.evictions.Add(1)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+// Purge removes every entry.
+func (s *MemoryStore) Purge(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lru.Init()
+	clear(s.items)
+	s.expiries = nil
+	s.bytes = 0
+	return nil
+}
+
+// Evictions returns the number of entries evicted for the bounds of the
+// store or their expiry.
+func (s *MemoryStore) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+// Len returns the number of entries in the store, including expired
+// entries not evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryStore) expire(now time.Time) {
+	for len(s.expiries) > 0 && !s.expiries[0].expiration.After(now) {
+		s.remove(s.items[s.expiries[0].key])
+		s.evictions.Add(1)
+	}
+}
+
+func (s *MemoryStore) remove(element *list.Element) {
+	item := element.Value.(*memoryItem)
+	s.lru.Remove(element)
+	delete(s.items, item.key)
+	heap.Remove(&s.expiries, item.index)
+	s.bytes -= item.size()
+}
+
+// expiryHeap orders items by expiration, soonest first.
+type expiryHeap []*memoryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiration.Before(h[j].expiration) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*memoryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,294 @@
+package cacheint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+)
+
+// streamRecord is the serialised form of a cached server stream.
+type streamRecord struct {
+	Header   http.Header       `json:"header,omitempty"`
+	Trailer  http.Header       `json:"trailer,omitempty"`
+	Messages [][]byte          `json:"messages"`
+	Versions map[string]string `json:"versions,omitempty"`
+
+	FreshUntil time.Time `json:"freshUntil"`
+}
+
+// streamReplay is a decoded streamRecord, ready to be sent.
+type streamReplay struct {
+	header   http.Header
+	trailer  http.Header
+	messages []proto.Message
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		spec := conn.Spec()
+		if i.streamEntryFunc == nil || spec.StreamType != connect.StreamTypeServer {
+			return next(ctx, conn)
+		}
+		typ, ok := i.requestTypes.Load(spec.Procedure)
+		if !ok {
+			// The request type is learned from the first stream of the
+			// procedure, which is not cached.
+			return next(ctx, &learningConn{StreamingHandlerConn: conn, interceptor: i})
+		}
+
+		msg := reflect.New(typ.(reflect.Type).Elem()).Interface().(proto.Message)
+		if err := conn.Receive(msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				// Leave the cardinality violation to the handler.
+				return next(ctx, conn)
+			}
+			return err
+		}
+		received := &receivedConn{StreamingHandlerConn: conn, msg: msg}
+		entry := i.streamEntryFunc(ctx, conn, msg)
+		if entry.Expiry == 0 {
+			return next(ctx, received)
+		}
+
+		key := storeKey(entry.Key)
+		if replay, ok := i.loadStream(ctx, spec.Procedure, key); ok {
+			i.hits.Add(1)
+			return replay.send(conn)
+		}
+		i.misses.Add(1)
+		return i.fetchStream(ctx, received, next, key, entry)
+	}
+}
+
+// fetchStream runs next and caches the stream it sends, through
+// single-flight if enabled. Coalesced callers replay the stream of the
+// leader once it ends.
+func (i *interceptor) fetchStream(
+	ctx context.Context, conn *receivedConn, next connect.StreamingHandlerFunc, key string, entry Entry,
+) error {
+	if !i.enableSingleFlight {
+		_, err := i.stream(ctx, conn, next, key, entry)
+		return err
+	}
+
+	leader := false
+	data, err, _ := i.Do(key, func() (any, error) {
+		leader = true
+		return i.stream(ctx, conn, next, key, entry)
+	})
+	if leader {
+		return err
+	}
+	i.coalesced.Add(1)
+	if err != nil {
+		return err
+	}
+	// Streams too long to be cached are not shared either.
+	if replay, ok := i.decodeStream(conn.Spec().Procedure, data.([]byte)); ok {
+		return replay.send(conn)
+	}
+	return next(ctx, conn)
+}
+
+// stream runs next, recording what it sends, and caches the recording. It
+// returns the serialised stream, or nil if it was not cached.
+func (i *interceptor) stream(
+	ctx context.Context, conn *receivedConn, next connect.StreamingHandlerFunc, key string, entry Entry,
+) ([]byte, error) {
+	// Versions are read before the call, so that an invalidation racing
+	// with it leaves the entry stale.
+	versions, err := i.versions(ctx, entry.Tags)
+	if err != nil {
+		return nil, next(ctx, conn)
+	}
+	recorder := &recordingConn{receivedConn: conn, maxBytes: i.streamMaxBytes}
+	if err := next(ctx, recorder); err != nil {
+		return nil, err
+	}
+	if recorder.uncached {
+		return nil, nil
+	}
+
+	procedure := conn.Spec().Procedure
+	if recorder.typ != nil {
+		i.messageTypes.Store(procedure, recorder.typ)
+	}
+	fresh := i.jitterFunc(entry.Expiry)
+	data, err := json.Marshal(streamRecord{
+		Header:   nonProtocolHeader(conn.ResponseHeader()),
+		Trailer:  nonProtocolHeader(conn.ResponseTrailer()),
+		Messages: recorder.messages,
+		Versions: versions,
+
+		FreshUntil: time.Now().Add(fresh),
+	})
+	if err != nil {
+		return nil, nil
+	}
+	_ = i.store.Set(ctx, key, data, fresh)
+	return data, nil
+}
+
+// loadStream returns the cached stream of key.
+func (i *interceptor) loadStream(ctx context.Context, procedure, key string) (*streamReplay, bool) {
+	data, ok, err := i.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+
+	var rec streamRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		_ = i.store.Delete(ctx, key)
+		return nil, false
+	}
+	if !time.Now().Before(rec.FreshUntil) || !i.current(ctx, key, rec.Versions) {
+		return nil, false
+	}
+	return i.buildStream(procedure, rec)
+}
+
+func (i *interceptor) decodeStream(procedure string, data []byte) (*streamReplay, bool) {
+	var rec streamRecord
+	if data == nil || json.Unmarshal(data, &rec) != nil {
+		return nil, false
+	}
+	return i.buildStream(procedure, rec)
+}
+
+// buildStream decodes the messages of rec into the response message type
+// of procedure. Entries are decoded in full before anything is sent, so
+// that a bad entry is a miss rather than a broken stream.
+func (i *interceptor) buildStream(procedure string, rec streamRecord) (*streamReplay, bool) {
+	replay := &streamReplay{header: rec.Header, trailer: rec.Trailer}
+	if len(rec.Messages) == 0 {
+		return replay, true
+	}
+	value, ok := i.messageTypes.Load(procedure)
+	if !ok {
+		return nil, false
+	}
+
+	typ := value.(reflect.Type).Elem()
+	for _, payload := range rec.Messages {
+		msg := reflect.New(typ).Interface().(proto.Message)
+		if err := proto.Unmarshal(payload, msg); err != nil {
+			return nil, false
+		}
+		replay.messages = append(replay.messages, msg)
+	}
+	return replay, true
+}
+
+func (r *streamReplay) send(conn connect.StreamingHandlerConn) error {
+	for k, v := range r.header {
+		conn.ResponseHeader()[k] = v
+	}
+	for k, v := range r.trailer {
+		conn.ResponseTrailer()[k] = v
+	}
+	for _, msg := range r.messages {
+		if err := conn.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nonProtocolHeader returns a copy of header without the headers set by
+// the Connect, gRPC and gRPC-Web protocols, which depend on the caller.
+func nonProtocolHeader(header http.Header) http.Header {
+	ret := make(http.Header, len(header))
+	for k, v := range header {
+		switch k = http.CanonicalHeaderKey(k); {
+		case len(v) == 0,
+			k == "Content-Type", k == "Content-Encoding", k == "Content-Length", k == "Date", k == "Trailer",
+			strings.HasPrefix(k, "Connect-"), strings.HasPrefix(k, "Grpc-"), strings.HasPrefix(k, "Trailer-"):
+			continue
+		}
+		ret[k] = slices.Clone(v)
+	}
+	return ret
+}
+
+// learningConn records the request type of its procedure on the first
+// message received.
+type learningConn struct {
+	connect.StreamingHandlerConn
+	interceptor *interceptor
+}
+
+func (c *learningConn) Receive(msg any) error {
+	if err := c.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	if _, ok := msg.(proto.Message); ok {
+		c.interceptor.requestTypes.LoadOrStore(c.Spec().Procedure, reflect.TypeOf(msg))
+	}
+	return nil
+}
+
+// receivedConn hands the request message the interceptor received to the
+// handler.
+type receivedConn struct {
+	connect.StreamingHandlerConn
+	msg proto.Message
+}
+
+func (c *receivedConn) Receive(msg any) error {
+	if c.msg == nil {
+		return c.StreamingHandlerConn.Receive(msg)
+	}
+	if reflect.TypeOf(msg) != reflect.TypeOf(c.msg) {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("cacheint: receive %T, want %T", msg, c.msg))
+	}
+	proto.Merge(msg.(proto.Message), c.msg)
+	c.msg = nil
+	return nil
+}
+
+// recordingConn records the messages sent, until they exceed maxBytes.
+type recordingConn struct {
+	*receivedConn
+	maxBytes int
+	size     int
+	typ      reflect.Type
+	messages [][]byte
+	uncached bool
+}
+
+func (c *recordingConn) Send(msg any) error {
+	if !c.uncached {
+		c.record(msg)
+	}
+	return c.receivedConn.Send(msg)
+}
+
+func (c *recordingConn) record(msg any) {
+	message, ok := msg.(proto.Message)
+	if !ok {
+		c.uncached, c.messages = true, nil
+		return
+	}
+	payload, err := proto.Marshal(message)
+	if err != nil || c.maxBytes > 0 && c.size+len(payload) > c.maxBytes {
+		c.uncached, c.messages = true, nil
+		return
+	}
+	c.size += len(payload)
+	c.typ = reflect.TypeOf(msg)
+	c.messages = append(c.messages, payload)
+}
@@ -0,0 +1,199 @@
+package ratelimitint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Rate limit headers set on responses and on rejections, following the
+// IETF RateLimit header fields draft. Values are whole seconds.
+const (
+	HEADER_RATELIMIT_LIMIT     = "RateLimit-Limit"
+	HEADER_RATELIMIT_REMAINING = "RateLimit-Remaining"
+	HEADER_RATELIMIT_RESET     = "RateLimit-Reset"
+	HEADER_RETRY_AFTER         = "Retry-After"
+)
+
+type interceptor struct {
+	store         Store
+	failOpen      bool
+	keyFunc       func(context.Context, connect.AnyRequest) (string, Limit)
+	streamKeyFunc func(context.Context, connect.StreamingHandlerConn) (string, Limit)
+}
+
+type option func(*config)
+
+type config struct {
+	store         Store
+	failOpen      bool
+	keyFunc       func(context.Context, connect.AnyRequest) (string, Limit)
+	streamKeyFunc func(context.Context, connect.StreamingHandlerConn) (string, Limit)
+}
+
+var defaultConfig = config{
+	failOpen: false,
+	keyFunc: func(ctx context.Context, ar connect.AnyRequest) (string, Limit) {
+		return "", Limit{}
+	},
+	streamKeyFunc: func(ctx context.Context, conn connect.StreamingHandlerConn) (string, Limit) {
+		return "", Limit{}
+	},
+}
+
+// WithKeyFunc sets the function mapping a unary request to its limiter
+// key and limit. Requests sharing a key share a quota, so keying by
+// caller and procedure, as in `peer + spec.Procedure`, limits each caller
+// per procedure. The function should return a zero Limit for requests
+// that are not limited. Default function limits nothing.
+func WithKeyFunc(f func(context.Context, connect.AnyRequest) (string, Limit)) option {
+	return func(c *config) {
+		if f == nil {
+			return
+		}
+		c.keyFunc = f
+	}
+}
+
+// WithStreamKeyFunc sets the function mapping a streaming call to its
+// limiter key and limit. Opening a stream takes one request from the
+// quota, messages on the stream do not. Default function limits nothing.
+func WithStreamKeyFunc(f func(context.Context, connect.StreamingHandlerConn) (string, Limit)) option {
+	return func(c *config) {
+		if f == nil {
+			return
+		}
+		c.streamKeyFunc = f
+	}
+}
+
+// WithStore sets the store keeping limiter state. Default is a new
+// MemoryStore.
+func WithStore(store Store) option {
+	return func(c *config) {
+		if store == nil {
+			return
+		}
+		c.store = store
+	}
+}
+
+// WithFailOpen lets requests through when the store fails instead of
+// rejecting them with CodeUnavailable. Default is disabled (false).
+func WithFailOpen(val bool) option {
+	return func(c *config) {
+		c.failOpen = val
+	}
+}
+
+// New creates a rate-limiting interceptor for unary and streaming
+// handlers. Requests over their limit fail with CodeResourceExhausted,
+// carrying a google.rpc.RetryInfo detail and the Retry-After and
+// RateLimit headers, and requests within their limit get the RateLimit
+// headers on their response. Outgoing client calls pass through
+// untouched.
+func New(opts ...option) connect.Interceptor {
+	config := defaultConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.store == nil {
+		config.store = NewMemoryStore()
+	}
+	return &interceptor{
+		store:         config.store,
+		failOpen:      config.failOpen,
+		keyFunc:       config.keyFunc,
+		streamKeyFunc: config.streamKeyFunc,
+	}
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, ar connect.AnyRequest) (connect.AnyResponse, error) {
+		if ar.Spec().IsClient {
+			return next(ctx, ar)
+		}
+		key, limit := i.keyFunc(ctx, ar)
+		decision, err := i.take(ctx, key, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next(ctx, ar)
+		if decision != nil {
+			var connectErr *connect.Error
+			switch {
+			case errors.As(err, &connectErr):
+				setHeaders(connectErr.Meta(), *decision)
+			case err == nil && resp != nil:
+				setHeaders(resp.Header(), *decision)
+			}
+		}
+		return resp, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		key, limit := i.streamKeyFunc(ctx, conn)
+		decision, err := i.take(ctx, key, limit)
+		if err != nil {
+			return err
+		}
+		if decision != nil {
+			setHeaders(conn.ResponseHeader(), *decision)
+		}
+		return next(ctx, conn)
+	}
+}
+
+// take returns the decision for an allowed request, nil if the request
+// is not limited, or the error rejecting it.
+func (i *interceptor) take(ctx context.Context, key string, limit Limit) (*Decision, error) {
+	if limit.IsZero() {
+		return nil, nil
+	}
+
+	decision, err := i.store.Take(ctx, key, limit)
+	if err != nil {
+		if i.failOpen {
+			return nil, nil
+		}
+		return nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("rate limiter: %w", err))
+	}
+	if decision.Allowed {
+		return &decision, nil
+	}
+
+	connectErr := connect.NewError(connect.CodeResourceExhausted, fmt.Errorf("rate limit exceeded, retry after %s", decision.RetryAfter))
+	if detail, err := connect.NewErrorDetail(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(decision.RetryAfter),
+	}); err == nil {
+		connectErr.AddDetail(detail)
+	}
+	setHeaders(connectErr.Meta(), decision)
+	connectErr.Meta().Set(HEADER_RETRY_AFTER, seconds(decision.RetryAfter))
+	return nil, connectErr
+}
+
+func setHeaders(header http.Header, decision Decision) {
+	header.Set(HEADER_RATELIMIT_LIMIT, strconv.Itoa(decision.Limit))
+	header.Set(HEADER_RATELIMIT_REMAINING, strconv.Itoa(decision.Remaining))
+	header.Set(HEADER_RATELIMIT_RESET, seconds(decision.Reset))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,126 @@
+package ratelimitint_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/humbornjo/mizu/mizuconnect/interceptor/ratelimitint"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimitint.Limit) (ratelimitint.Decision, error) {
+	return ratelimitint.Decision{}, errors.New("store is down")
+}
+
+func TestRatelimitint_MemoryStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit ratelimitint.Limit
+		calls int
+	}{
+		{
+			name:  "token bucket",
+			limit: ratelimitint.Limit{Algorithm: ratelimitint.ALGORITHM_TOKEN_BUCKET, Requests: 2, Period: time.Hour},
+			calls: 2,
+		},
+		{
+			name:  "token bucket burst",
+			limit: ratelimitint.Limit{Algorithm: ratelimitint.ALGORITHM_TOKEN_BUCKET, Requests: 1, Period: time.Hour, Burst: 3},
+			calls: 3,
+		},
+		{
+			name:  "sliding window",
+			limit: ratelimitint.Limit{Algorithm: ratelimitint.ALGORITHM_SLIDING_WINDOW, Requests: 3, Period: time.Hour},
+			calls: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := ratelimitint.NewMemoryStore()
+			for call := range tc.calls {
+				decision, err := store.Take(context.Background(), "caller", tc.limit)
+				require.NoError(t, err)
+				assert.True(t, decision.Allowed)
+				assert.Equal(t, tc.calls-call-1, decision.Remaining)
+			}
+
+			decision, err := store.Take(context.Background(), "caller", tc.limit)
+			require.NoError(t, err)
+			assert.False(t, decision.Allowed)
+			assert.Equal(t, 0, decision.Remaining)
+			assert.Positive(t, decision.RetryAfter)
+			assert.LessOrEqual(t, decision.RetryAfter, 2*tc.limit.Period)
+
+			decision, err = store.Take(context.Background(), "other", tc.limit)
+			require.NoError(t, err)
+			assert.True(t, decision.Allowed, "keys have separate quotas")
+		})
+	}
+}
+
+func TestRatelimitint_New(t *testing.T) {
+	limit := ratelimitint.Limit{Requests: 1, Period: time.Hour}
+	newClient := func(store ratelimitint.Store, failOpen bool) func() (*connect.Response[emptypb.Empty], error) {
+		procedure := "/test.v1.Service/Method"
+		mux := http.NewServeMux()
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+			func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			},
+			connect.WithInterceptors(ratelimitint.New(
+				ratelimitint.WithStore(store),
+				ratelimitint.WithFailOpen(failOpen),
+				ratelimitint.WithKeyFunc(func(_ context.Context, ar connect.AnyRequest) (string, ratelimitint.Limit) {
+					return ar.Header().Get("X-Caller") + ar.Spec().Procedure, limit
+				}),
+			)),
+		))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+procedure)
+		return func() (*connect.Response[emptypb.Empty], error) {
+			req := connect.NewRequest(&emptypb.Empty{})
+			req.Header().Set("X-Caller", "caller")
+			return client.CallUnary(context.Background(), req)
+		}
+	}
+
+	call := newClient(nil, false)
+	resp, err := call()
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.Header().Get(ratelimitint.HEADER_RATELIMIT_LIMIT))
+	assert.Equal(t, "0", resp.Header().Get(ratelimitint.HEADER_RATELIMIT_REMAINING))
+	assert.Equal(t, "3600", resp.Header().Get(ratelimitint.HEADER_RATELIMIT_RESET))
+
+	_, err = call()
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	assert.Equal(t, connect.CodeResourceExhausted, connectErr.Code())
+	assert.Equal(t, "3600", connectErr.Meta().Get(ratelimitint.HEADER_RETRY_AFTER))
+	assert.Equal(t, "0", connectErr.Meta().Get(ratelimitint.HEADER_RATELIMIT_REMAINING))
+	require.Len(t, connectErr.Details(), 1)
+	detail, err := connectErr.Details()[0].Value()
+	require.NoError(t, err)
+	retryInfo, ok := detail.(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.InDelta(t, time.Hour, retryInfo.GetRetryDelay().AsDuration(), float64(time.Second))
+
+	_, err = newClient(failingStore{}, false)()
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+	_, err = newClient(failingStore{}, true)()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,175 @@
+package ratelimitint
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Algorithm selects how a Limit counts requests.
+type Algorithm int
+
+const (
+	// ALGORITHM_TOKEN_BUCKET refills Requests tokens per Period up to Burst
+	// tokens, and every request takes one. It allows short bursts while
+	// holding the average rate.
+	ALGORITHM_TOKEN_BUCKET Algorithm = iota
+	// ALGORITHM_SLIDING_WINDOW allows Requests requests in any window of
+	// Period, estimated from the counts of the current and the previous
+	// fixed window.
+	ALGORITHM_SLIDING_WINDOW
+)
+
+// Limit is the rate a key may be called at. The zero Limit disables rate
+// limiting.
+type Limit struct {
+	Algorithm Algorithm
+	Requests  int
+	Period    time.Duration
+	// Burst is the token bucket capacity. Default is Requests.
+	Burst int
+}
+
+// IsZero reports whether the limit disables rate limiting.
+func (l Limit) IsZero() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Decision is the outcome of taking one request from a key.
+type Decision struct {
+	Allowed bool
+	// Limit is the number of requests the key may make at once.
+	Limit int
+	// Remaining is the number of requests left after this one.
+	Remaining int
+	// Reset is the time until the quota is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until a rejected request may succeed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the limiter state of every key. Implementations backed by
+// shared storage let several replicas enforce one limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+const _MEMORY_SWEEP_INTERVAL = time.Minute
+
+// MemoryStore is a Store local to the process. Keys whose state returned
+// to a full quota are dropped periodically.
+type MemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]*state
+	lastSweep time.Time
+}
+
+type state struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+
+	// sliding window
+	window   time.Time
+	current  int
+	previous int
+
+	idle time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]*state), lastSweep: time.Now()}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > _MEMORY_SWEEP_INTERVAL {
+		for key, st := range s.states {
+			if now.After(st.idle) {
+				delete(s.states, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	st, ok := s.states[key]
+	if !ok {
+		st = &state{tokens: float64(limit.burst()), last: now, window: now.Truncate(limit.Period)}
+		s.states[key] = st
+	}
+	if limit.Algorithm == ALGORITHM_SLIDING_WINDOW {
+		return st.slide(now, limit), nil
+	}
+	return st.refill(now, limit), nil
+}
+
+func (st *state) refill(now time.Time, limit Limit) Decision {
+	burst := float64(limit.burst())
+	interval := limit.Period / time.Duration(limit.Requests)
+	if elapsed := now.Sub(st.last); elapsed > 0 {
+		st.tokens = math.Min(burst, st.tokens+float64(elapsed)/float64(interval))
+		st.last = now
+	}
+
+	decision := Decision{Limit: int(burst)}
+	if st.tokens >= 1 {
+		st.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - st.tokens) * float64(interval))
+	}
+	decision.Remaining = int(st.tokens)
+	decision.Reset = time.Duration((burst - st.tokens) * float64(interval))
+	st.idle = now.Add(decision.Reset)
+	return decision
+}
+
+func (st *state) slide(now time.Time, limit Limit) Decision {
+	period := limit.Period
+	window := now.Truncate(period)
+	switch {
+	case window.Sub(st.window) >= 2*period:
+		st.previous, st.current = 0, 0
+	case window.Sub(st.window) >= period:
+		st.previous, st.current = st.current, 0
+	}
+	st.window = window
+
+	requests := float64(limit.Requests)
+	elapsed := float64(now.Sub(window)) / float64(period)
+	estimate := float64(st.previous)*(1-elapsed) + float64(st.current)
+
+	decision := Decision{Limit: limit.Requests, Reset: period - now.Sub(window)}
+	if estimate+1 <= requests {
+		st.current++
+		estimate++
+		decision.Allowed = true
+	} else if float64(st.current)+1 <= requests {
+		// The previous window has to fade out far enough.
+		fade := (1 - elapsed) - (requests-float64(st.current)-1)/float64(st.previous)
+		decision.RetryAfter = time.Duration(fade * float64(period))
+	} else {
+		// Only the next window has room, once this one fades out far enough.
+		fade := math.Max(0, 1-(requests-1)/float64(st.current))
+		decision.RetryAfter = decision.Reset + time.Duration(fade*float64(period))
+	}
+	decision.Remaining = max(0, int(requests-estimate))
+	st.idle = window.Add(2 * period)
+	return decision
+}
@@ -0,0 +1,121 @@
+package mizuconnect
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type procedureMiddleware struct {
+	pattern     string
+	middlewares []func(http.Handler) http.Handler
+}
+
+// WithProcedureMiddleware applies HTTP middlewares to the procedures
+// matching pattern, a full procedure name or a path.Match glob over it
+// such as `/acme.user.v1.UserService/Admin*`. The first middleware runs
+// first, and middlewares of earlier options run before those of later
+// ones. They also apply to requests transcoded by Vanguard and proxied
+// by gRPC-gateway, which reach the procedure through the same handler.
+func WithProcedureMiddleware(pattern string, middlewares ...func(http.Handler) http.Handler) Option {
+	return func(m *config) {
+		m.procedureMiddlewares = append(m.procedureMiddlewares,
+			procedureMiddleware{pattern: pattern, middlewares: middlewares})
+	}
+}
+
+// WithProcedureInterceptors applies Connect interceptors to the
+// procedures matching pattern, like WithProcedureMiddleware. They run in
+// the order of WithCrpcHandlerOptions interceptors, relative to the
+// options they are given with.
+func WithProcedureInterceptors(pattern string, interceptors ...connect.Interceptor) Option {
+	return func(m *config) {
+		for _, interceptor := range interceptors {
+			m.connectOpts = append(m.connectOpts, connect.WithInterceptors(
+				&procedureInterceptor{pattern: pattern, inner: interceptor}))
+		}
+	}
+}
+
+// procedure wraps the handler of service, mounted on pattern, with the
+// middlewares of its procedures.
+func (c *config) procedure(pattern string, sd protoreflect.ServiceDescriptor, handler http.Handler) http.Handler {
+	handlers := make(map[string]http.Handler)
+	methods := sd.Methods()
+	for i := range methods.Len() {
+		name := string(methods.Get(i).Name())
+		var middlewares []func(http.Handler) http.Handler
+		for _, m := range c.procedureMiddlewares {
+			if ok, _ := path.Match(m.pattern, pattern+name); ok {
+				middlewares = append(middlewares, m.middlewares...)
+			}
+		}
+		if len(middlewares) == 0 {
+			continue
+		}
+		wrapped := handler
+		for _, middleware := range slices.Backward(middlewares) {
+			wrapped = middleware(wrapped)
+		}
+		handlers[name] = wrapped
+	}
+	if len(handlers) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, name, _ := strings.Cut(r.URL.Path, pattern)
+		name, _, _ = strings.Cut(name, "/")
+		if wrapped, ok := handlers[name]; ok {
+			wrapped.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// procedureInterceptor applies inner to the procedures matching pattern.
+type procedureInterceptor struct {
+	pattern string
+	inner   connect.Interceptor
+}
+
+func (i *procedureInterceptor) match(procedure string) bool {
+	ok, _ := path.Match(i.pattern, procedure)
+	return ok
+}
+
+func (i *procedureInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	wrapped := i.inner.WrapUnary(next)
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if i.match(req.Spec().Procedure) {
+			return wrapped(ctx, req)
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *procedureInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	wrapped := i.inner.WrapStreamingClient(next)
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		if i.match(spec.Procedure) {
+			return wrapped(ctx, spec)
+		}
+		return next(ctx, spec)
+	}
+}
+
+func (i *procedureInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	wrapped := i.inner.WrapStreamingHandler(next)
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if i.match(conn.Spec().Procedure) {
+			return wrapped(ctx, conn)
+		}
+		return next(ctx, conn)
+	}
+}
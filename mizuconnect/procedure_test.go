@@ -0,0 +1,78 @@
+package mizuconnect_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizuconnect"
+)
+
+func TestMizuConnect_WithProcedureMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				seen = append(seen, name+" "+r.URL.Path)
+				mu.Unlock()
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	deny := connect.UnaryInterceptorFunc(func(connect.UnaryFunc) connect.UnaryFunc {
+		return func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			return nil, connect.NewError(connect.CodePermissionDenied, errors.New("admins only"))
+		}
+	})
+
+	srv := mizu.NewServer("test")
+	scope := mizuconnect.NewScope(srv,
+		mizuconnect.WithCrpcVanguard("/"),
+		mizuconnect.WithProcedureMiddleware("/*/G*", record("outer"), record("inner")),
+		mizuconnect.WithProcedureMiddleware(_CACHE_SERVICE+"Get", record("exact")),
+		mizuconnect.WithProcedureInterceptors(_CACHE_SERVICE+"Put", deny),
+	)
+	scope.Register(&cacheServiceImpl{value: "mizu"}, newCacheServiceHandler)
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	call := func(method string) error {
+		client := connect.NewClient[structpb.Value, structpb.Value](server.Client(), server.URL+_CACHE_SERVICE+method)
+		_, err := client.CallUnary(context.Background(), connect.NewRequest(structpb.NewStringValue("req")))
+		return err
+	}
+	take := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		ret := seen
+		seen = nil
+		return ret
+	}
+
+	require.NoError(t, call("Get"))
+	get := _CACHE_SERVICE + "Get"
+	assert.Equal(t, []string{"outer " + get, "inner " + get, "exact " + get}, take())
+
+	err := call("Put")
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	assert.Empty(t, take(), "middlewares of other procedures do not apply")
+
+	resp, err := server.Client().Post(server.URL+"/v1/cache", "application/json", strings.NewReader(`"req"`))
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"outer " + get, "inner " + get, "exact " + get}, take(),
+		"middlewares apply to requests transcoded by Vanguard")
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"))
+}
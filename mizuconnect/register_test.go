@@ -0,0 +1,138 @@
+package mizuconnect_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizuconnect"
+)
+
+func TestMizuConnect_Register(t *testing.T) {
+	srv := mizu.NewServer("test")
+	mizuconnect.NewScope(srv).Register(&cacheServiceImpl{value: "mizu"}, newCacheServiceHandler)
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+
+	// The service is mounted on its pattern with the trailing slash, so
+	// that every procedure below it is routed.
+	for _, procedure := range []string{"Get", "Put"} {
+		client := connect.NewClient[structpb.Value, structpb.Value](server.Client(), server.URL+_CACHE_SERVICE+procedure)
+		resp, err := client.CallUnary(context.Background(), connect.NewRequest(structpb.NewStringValue("req")))
+		require.NoError(t, err, procedure)
+		assert.Equal(t, "mizu", resp.Msg.GetStringValue())
+	}
+}
+
+func TestMizuConnect_TryRegister(t *testing.T) {
+	dialErr := errors.New("dial failed")
+	gatewayFunc := func(err error) func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error {
+		return func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error { return err }
+	}
+	unknownService := func(cacheService, ...connect.HandlerOption) (string, http.Handler) {
+		return "/mizuconnect.test.v1.UnknownService/", http.NotFoundHandler()
+	}
+
+	cases := []struct {
+		name     string
+		register func(*mizuconnect.Scope) error
+		want     error
+	}{
+		{"not a function", func(s *mizuconnect.Scope) error {
+			return s.TryRegister(&cacheServiceImpl{}, "newCacheServiceHandler")
+		}, mizuconnect.ErrInvalidConstructor},
+		{"wrong implementation", func(s *mizuconnect.Scope) error {
+			return s.TryRegister(struct{}{}, newCacheServiceHandler)
+		}, mizuconnect.ErrInvalidConstructor},
+		{"nil implementation", func(s *mizuconnect.Scope) error {
+			return s.TryRegister(nil, newCacheServiceHandler)
+		}, mizuconnect.ErrInvalidConstructor},
+		{"unknown descriptor", func(s *mizuconnect.Scope) error {
+			return s.TryRegister(&cacheServiceImpl{}, unknownService)
+		}, mizuconnect.ErrDescriptorNotFound},
+		{"gateway disabled", func(s *mizuconnect.Scope) error {
+			return s.UseGateway(gatewayFunc(nil)).TryRegister(&cacheServiceImpl{}, newCacheServiceHandler)
+		}, mizuconnect.ErrGatewayDisabled},
+		{"gateway failure", func(s *mizuconnect.Scope) error {
+			scope := mizuconnect.NewScope(mizu.NewServer("test"), mizuconnect.WithGrpcGateway(context.Background(), "", ":0"))
+			return scope.UseGateway(gatewayFunc(dialErr)).TryRegister(&cacheServiceImpl{}, newCacheServiceHandler)
+		}, dialErr},
+	}
+	assert.Panics(t, func() {
+		mizuconnect.NewScope(mizu.NewServer("test")).Register(&cacheServiceImpl{}, unknownService)
+	})
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			scope := mizuconnect.NewScope(mizu.NewServer("test"))
+			err := c.register(scope)
+			require.ErrorIs(t, err, c.want)
+			var registerErr mizuconnect.RegisterError
+			assert.ErrorAs(t, err, &registerErr)
+			assert.Empty(t, scope.Report())
+		})
+	}
+
+	for name, c := range map[string]struct{ impl, newFunc any }{
+		"gateway invalid constructor": {struct{}{}, newCacheServiceHandler},
+		"gateway unknown descriptor":  {&cacheServiceImpl{}, unknownService},
+	} {
+		t.Run(name, func(t *testing.T) {
+			called := false
+			scope := mizuconnect.NewScope(mizu.NewServer("test"), mizuconnect.WithGrpcGateway(context.Background(), "", ":0"))
+			err := scope.UseGateway(func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error {
+				called = true
+				return nil
+			}).TryRegister(c.impl, c.newFunc)
+			var registerErr mizuconnect.RegisterError
+			require.ErrorAs(t, err, &registerErr)
+			assert.False(t, called, "gateway routes are registered once the service is validated")
+			assert.Empty(t, scope.Report())
+		})
+	}
+}
+
+func TestMizuConnect_Report(t *testing.T) {
+	procedures := []string{_CACHE_SERVICE + "Get", _CACHE_SERVICE + "Put"}
+
+	srv := mizu.NewServer("test")
+	mizuconnect.NewScope(srv, mizuconnect.WithGrpcHealth())
+	scope := mizuconnect.NewScope(srv,
+		mizuconnect.WithPrefix("/rpc"),
+		mizuconnect.WithCrpcValidate(),
+		mizuconnect.WithCrpcVanguard(""),
+	)
+	scope.Register(&cacheServiceImpl{}, newCacheServiceHandler)
+	assert.Equal(t, []mizuconnect.ServiceReport{{
+		Name:         "mizuconnect.test.v1.CacheService",
+		Procedures:   procedures,
+		ConnectPath:  "/rpc" + _CACHE_SERVICE,
+		VanguardPath: "/rpc",
+		Validate:     true,
+		Health:       true,
+	}}, scope.Report(), "health enabled by another scope serves the service too")
+
+	scope = mizuconnect.NewScope(mizu.NewServer("test"),
+		mizuconnect.WithGrpcReflect(),
+		mizuconnect.WithGrpcGateway(context.Background(), "/gateway/", ":8080"),
+	)
+	require.NoError(t, scope.UseGateway(
+		func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error { return nil },
+	).TryRegister(&cacheServiceImpl{}, newCacheServiceHandler))
+	assert.Equal(t, []mizuconnect.ServiceReport{{
+		Name:        "mizuconnect.test.v1.CacheService",
+		Procedures:  procedures,
+		ConnectPath: _CACHE_SERVICE,
+		GatewayPath: "/gateway/",
+		Reflect:     true,
+	}}, scope.Report())
+}
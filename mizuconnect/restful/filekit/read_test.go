@@ -2,6 +2,7 @@ package filekit_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -11,6 +12,7 @@ import (
 	"testing"
 
 	"connectrpc.com/connect"
+	"github.com/humbornjo/mizu"
 	"github.com/humbornjo/mizu/mizuconnect/restful/filekit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -276,6 +278,45 @@ func TestFilekit_Read_FormReader(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("test file part with progress and rate limit", func(t *testing.T) {
+		body := bytes.NewBuffer(nil)
+		writer := multipart.NewWriter(body)
+		file, err := writer.CreateFormFile("upload", "test.txt")
+		require.NoError(t, err)
+		_, err = file.Write(bytes.Repeat([]byte("m"), 32))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		contentType := writer.FormDataContentType()
+		stream := NewMockStreamForm(
+			NewFormFrame(contentType, body.Bytes()[:body.Len()/2]),
+			NewFormFrame(contentType, body.Bytes()[body.Len()/2:]),
+		)
+		reader, err := filekit.NewFormReader("upload", stream, nil)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		part, _, err := reader.File()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var reports []int64
+		rxFile := mizu.NewFileReader(part,
+			mizu.WithFileContext(ctx),
+			mizu.WithFileRateLimit(1<<20, 8),
+			mizu.WithFileProgress(func(readBytes int64) { reports = append(reports, readBytes) }, 16, 0),
+		)
+		data, err := io.ReadAll(rxFile)
+		require.NoError(t, err)
+		assert.Len(t, data, 32)
+		assert.Equal(t, []int64{16, 32}, reports)
+
+		cancel()
+		_, err = rxFile.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
 }
 
 func TestFilekit_Read_FileReader(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"maps"
 	"net/http"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
 	"text/template"
@@ -259,11 +260,25 @@ type handler[I any, O any] func(Tx[O], Rx[I])
 
 // newHandler wraps the user-provided handler with request parsing
 // logic.
-func (h handler[I, O]) newHandler() http.HandlerFunc {
+func (h handler[I, O]) newHandler(fieldMask *operationFieldMask) http.HandlerFunc {
 	encoder := newEncoder[O]()
 	decoder := newDecoder[I]()
 	return func(w http.ResponseWriter, r *http.Request) {
 		tx := Tx[O]{w, func(val *O) error {
+			if fieldMask != nil {
+				_, err := mizu.FilterFieldMaskResponse(w, r, fieldMask.allowed, val, fieldMask.opts...)
+				var errs mizu.FieldMaskErrors
+				if errors.As(err, &errs) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(errs)
+					return err
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return err
+				}
+			}
 			return encoder.encode(w, val)
 		}}
 		rx := Rx[I]{r, func(r *http.Request) (input I, err error) {
@@ -289,6 +304,9 @@ func handle[I any, O any](
 		panic("oai not initialized, call Initialize first")
 	}
 
+	if config.fieldMask != nil && reflect.TypeFor[O]().Kind() != reflect.Struct {
+		panic(fmt.Errorf("register OpenAPI operation: field mask output must be a struct, got %v", reflect.TypeFor[O]()))
+	}
 	if config.external == nil {
 		components := newComponents()
 		enrichOperation[I, O](config, oai.reflector.withSchemas(components.Schemas))
@@ -297,7 +315,7 @@ func handle[I any, O any](
 	if err := oai.addOperation(config); err != nil {
 		panic(fmt.Errorf("register OpenAPI operation: %w", err))
 	}
-	registerHandler(method, srv, pattern, handler[I, O](oaiHandler).newHandler())
+	registerHandler(method, srv, pattern, handler[I, O](oaiHandler).newHandler(config.fieldMask))
 	return &config.Operation
 }
 
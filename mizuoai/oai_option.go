@@ -6,6 +6,7 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/humbornjo/mizu"
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
@@ -486,6 +487,7 @@ type operationConfig struct {
 	components      *v3.Components
 	pathItem        *v3.PathItem
 	documentTags    []*base.Tag
+	fieldMask       *operationFieldMask
 	err             error
 
 	path   string
@@ -577,6 +579,34 @@ func WithOperationParameters(parameters ...*v3.Parameter) OperationOption {
 	}
 }
 
+// WithOperationFieldMask filters the output of a typed handler by the
+// fields the request selects through the `fields` or `$select` query
+// parameter, intersected with allowed, right before MizuWrite encodes it.
+// Filtered responses carry mizu.HEADER_FIELD_MASK, and requests rejected
+// by mizu.WithFieldMaskStrict are answered with 400 and the
+// mizu.FieldMaskErrors. The `fields` parameter is added to the operation.
+func WithOperationFieldMask(allowed []string, opts ...mizu.FieldMaskOption) OperationOption {
+	return func(c *operationConfig) {
+		c.fieldMask = &operationFieldMask{allowed: slices.Clone(allowed), opts: opts}
+		merged, err := mergeParameters(c.Parameters, []*v3.Parameter{{
+			Name:        "fields",
+			In:          _STRUCT_TAG_QUERY.String(),
+			Description: "Comma separated response fields, e.g. `id,author(name),items/title`",
+			Schema:      base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
+		}})
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.Parameters = merged
+	}
+}
+
+type operationFieldMask struct {
+	allowed []string
+	opts    []mizu.FieldMaskOption
+}
+
 // WithOperation uses a complete OpenAPI operation. Typed reflection is
 // skipped, making the supplied operation authoritative.
 func WithOperation(operation *v3.Operation) OperationOption {
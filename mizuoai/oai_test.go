@@ -314,3 +314,74 @@ func TestMizuOai_Rx_Read_FormData(t *testing.T) {
 		})
 	}
 }
+
+type TestOutputFieldMask struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	Author struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+type TestInputFieldMask struct {
+	Path struct {
+		Id string `json:"id"`
+	} `json:"path"`
+}
+
+func TestMizuOai_Tx_FieldMask(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		status   int
+		header   string
+		expected string
+	}{
+		{
+			name:     "no selection",
+			target:   "/books/1",
+			status:   http.StatusOK,
+			expected: `{"id":"1","name":"mizu","secret":"s","author":{"name":"a","email":"a@example.com"}}`,
+		},
+		{
+			name:     "selection",
+			target:   "/books/1?fields=id,author(email)",
+			status:   http.StatusOK,
+			header:   "author.email,id",
+			expected: `{"id":"1","name":"","secret":"","author":{"name":"","email":"a@example.com"}}`,
+		},
+		{
+			name:     "strict rejection",
+			target:   "/books/1?fields=id,secret",
+			status:   http.StatusBadRequest,
+			expected: `[{"path":"secret","reason":"field is not allowed"}]`,
+		},
+	}
+
+	srv := mizu.NewServer("test")
+	require.NoError(t, mizuoai.Initialize(srv, "test_title"))
+	operation := mizuoai.Get(srv, "/books/{id}", func(tx mizuoai.Tx[TestOutputFieldMask], rx mizuoai.Rx[TestInputFieldMask]) {
+		input, err := rx.MizuRead()
+		require.NoError(t, err)
+		output := TestOutputFieldMask{Id: input.Path.Id, Name: "mizu", Secret: "s"}
+		output.Author.Name, output.Author.Email = "a", "a@example.com"
+		_ = tx.MizuWrite(&output)
+	}, mizuoai.WithOperationFieldMask([]string{"id", "name", "author"}, mizu.WithFieldMaskStrict()))
+	parameters := make([]string, 0, len(operation.Parameters))
+	for _, parameter := range operation.Parameters {
+		parameters = append(parameters, parameter.In+"/"+parameter.Name)
+	}
+	assert.ElementsMatch(t, []string{"query/fields", "path/id"}, parameters)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.header, w.Header().Get(mizu.HEADER_FIELD_MASK))
+			assert.JSONEq(t, tc.expected, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/humbornjo/mizu/mizutus
+
+go 1.26
+
+replace github.com/humbornjo/mizu => ../
+
+require (
+	github.com/humbornjo/mizu v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.11.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
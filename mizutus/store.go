@@ -0,0 +1,276 @@
+package mizutus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// ErrUploadNotFound is returned by a Store when the upload does not
+	// exist.
+	ErrUploadNotFound = errors.New("upload not found")
+
+	// ErrOffsetMismatch is returned by Store.Write when the requested
+	// offset differs from the persisted offset.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+	// ErrUploadLocked is returned by Store.Write, Store.SetLength and
+	// Store.Delete when another request to the same upload is in progress.
+	ErrUploadLocked = errors.New("upload is locked by another request")
+)
+
+// Info describes the persisted state of an upload. Length is negative
+// while the final size is still deferred.
+type Info struct {
+	Id       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"-"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Store persists resumable uploads. Implementations must be safe for
+// concurrent use and must derive Info.Offset from the bytes actually
+// persisted, so an interrupted request can be resumed from where it
+// stopped.
+type Store interface {
+	// Create registers a new empty upload.
+	Create(ctx context.Context, info Info) error
+
+	// Info returns the current state of an upload.
+	Info(ctx context.Context, id string) (Info, error)
+
+	// Write appends src to the upload at offset and returns the number of
+	// bytes persisted, including the bytes persisted before src failed.
+	Write(ctx context.Context, id string, offset int64, src io.Reader) (int64, error)
+
+	// SetLength declares the final length of an upload created with a
+	// deferred length.
+	SetLength(ctx context.Context, id string, length int64) error
+
+	// Open returns a reader over the persisted bytes of an upload.
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+
+	// Delete removes an upload and its bytes. It fails with
+	// ErrUploadLocked while a write to the upload is in progress.
+	Delete(ctx context.Context, id string) error
+}
+
+var _ Store = (*LocalStore)(nil)
+
+// LocalStore is a Store backed by a directory on the local file system.
+// Each upload is kept as an `<id>.bin` data file next to an `<id>.info`
+// JSON file. The offset of an upload is the size of its data file.
+type LocalStore struct {
+	dir  string
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// NewLocalStore creates a LocalStore rooted at dir, creating the
+// directory when it does not exist.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	return &LocalStore{dir: dir, busy: make(map[string]bool)}, nil
+}
+
+func (s *LocalStore) Create(ctx context.Context, info Info) error {
+	if err := validateId(info.Id); err != nil {
+		return err
+	}
+	data, err := os.OpenFile(s.path(info.Id, ".bin"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create upload %s: %w", info.Id, err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("create upload %s: %w", info.Id, err)
+	}
+	return s.writeInfo(info)
+}
+
+func (s *LocalStore) Info(ctx context.Context, id string) (Info, error) {
+	if err := validateId(id); err != nil {
+		return Info{}, err
+	}
+	raw, err := os.ReadFile(s.path(id, ".info"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("read upload %s: %w", id, err)
+	}
+	var info Info
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return Info{}, fmt.Errorf("decode upload %s: %w", id, err)
+	}
+	stat, err := os.Stat(s.path(id, ".bin"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("stat upload %s: %w", id, err)
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+func (s *LocalStore) Write(ctx context.Context, id string, offset int64, src io.Reader) (int64, error) {
+	if err := validateId(id); err != nil {
+		return 0, err
+	}
+	unlock, err := s.lock(id)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	data, err := os.OpenFile(s.path(id, ".bin"), os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open upload %s: %w", id, err)
+	}
+	defer data.Close() // nolint: errcheck
+
+	stat, err := data.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stat upload %s: %w", id, err)
+	}
+	if stat.Size() != offset {
+		return 0, fmt.Errorf("%w: expected %d, got %d", ErrOffsetMismatch, stat.Size(), offset)
+	}
+
+	n, err := io.Copy(data, src)
+	if syncErr := data.Sync(); err == nil && syncErr != nil {
+		err = fmt.Errorf("sync upload %s: %w", id, syncErr)
+	}
+	return n, err
+}
+
+func (s *LocalStore) SetLength(ctx context.Context, id string, length int64) error {
+	if err := validateId(id); err != nil {
+		return err
+	}
+	unlock, err := s.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := s.Info(ctx, id)
+	if err != nil {
+		return err
+	}
+	if info.Length >= 0 && info.Length != length {
+		return fmt.Errorf("upload %s length is already %d", id, info.Length)
+	}
+	info.Length = length
+	return s.writeInfo(info)
+}
+
+func (s *LocalStore) Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	if err := validateId(id); err != nil {
+		return nil, err
+	}
+	data, err := os.Open(s.path(id, ".bin"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open upload %s: %w", id, err)
+	}
+	return data, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, id string) error {
+	if err := validateId(id); err != nil {
+		return err
+	}
+	unlock, err := s.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	infoErr := os.Remove(s.path(id, ".info"))
+	dataErr := os.Remove(s.path(id, ".bin"))
+	if errors.Is(infoErr, fs.ErrNotExist) && errors.Is(dataErr, fs.ErrNotExist) {
+		return ErrUploadNotFound
+	}
+	if infoErr != nil && !errors.Is(infoErr, fs.ErrNotExist) {
+		return fmt.Errorf("delete upload %s: %w", id, infoErr)
+	}
+	if dataErr != nil && !errors.Is(dataErr, fs.ErrNotExist) {
+		return fmt.Errorf("delete upload %s: %w", id, dataErr)
+	}
+	return nil
+}
+
+func (s *LocalStore) writeInfo(info Info) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encode upload %s: %w", info.Id, err)
+	}
+	// Replace the info file atomically so a crash never leaves it torn.
+	// The temporary file is unique to this writer.
+	temp, err := os.CreateTemp(s.dir, info.Id+".info.*.tmp")
+	if err != nil {
+		return fmt.Errorf("write upload %s: %w", info.Id, err)
+	}
+	defer os.Remove(temp.Name()) // nolint: errcheck
+	_, err = temp.Write(raw)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write upload %s: %w", info.Id, err)
+	}
+	if err := os.Rename(temp.Name(), s.path(info.Id, ".info")); err != nil {
+		return fmt.Errorf("write upload %s: %w", info.Id, err)
+	}
+	return nil
+}
+
+// lock marks the upload busy until the returned function is called, or
+// fails with ErrUploadLocked when another request holds it.
+func (s *LocalStore) lock(id string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.busy[id] {
+		return nil, ErrUploadLocked
+	}
+	s.busy[id] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.busy, id)
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *LocalStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+func validateId(id string) error {
+	if id == "" || len(id) > 128 {
+		return fmt.Errorf("%w: invalid upload id", ErrUploadNotFound)
+	}
+	for _, char := range id {
+		if 'A' <= char && char <= 'Z' || 'a' <= char && char <= 'z' || '0' <= char && char <= '9' ||
+			char == '-' || char == '_' {
+			continue
+		}
+		return fmt.Errorf("%w: invalid upload id", ErrUploadNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+package mizutus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/humbornjo/mizu"
+)
+
+const (
+	_TUS_VERSION    = "1.0.0"
+	_TUS_EXTENSIONS = "creation,creation-defer-length,creation-with-upload,termination"
+
+	_IETF_INTEROP_VERSION = "6"
+	_IETF_PROBLEM_TYPE    = "https://iana.org/assignments/http-problem-types#"
+
+	_CONTENT_TYPE_TUS  = "application/offset+octet-stream"
+	_CONTENT_TYPE_IETF = "application/partial-upload"
+
+	_STATUS_UPLOAD_RESUMPTION_SUPPORTED = 104
+)
+
+// Upload is handed to the completion hook once every byte of an upload
+// has been persisted. Checksum is the hex SHA-256 of the whole upload
+// and ContentType is sniffed from its first 512 bytes, both computed by
+// mizu.FileReader.
+type Upload struct {
+	Info
+	Checksum    string
+	ContentType string
+}
+
+type config struct {
+	maxSize  int64
+	complete func(context.Context, Upload) error
+}
+
+// Option configures the resumable upload endpoints.
+type Option func(*config)
+
+// WithMaxSize sets the maximum size of a single upload. It is advertised
+// with Tus-Max-Size and enforced through mizu.WithFileLimitBytes on every
+// appended chunk. Default is unlimited.
+func WithMaxSize(size int64) Option {
+	return func(c *config) {
+		c.maxSize = size
+	}
+}
+
+// WithCompleteHook sets a function called after the request that
+// persists the last byte of an upload. A returned error is reported to
+// that client with status 500; the upload itself remains stored.
+func WithCompleteHook(hook func(context.Context, Upload) error) Option {
+	return func(c *config) {
+		c.complete = hook
+	}
+}
+
+type handler struct {
+	config
+	store Store
+	base  string
+}
+
+// Register mounts resumable upload endpoints on srv. Uploads are
+// created with POST on pattern and resumed on pattern/{id}. Both the tus
+// 1.0 core protocol (with the creation, creation-defer-length,
+// creation-with-upload and termination extensions) and the IETF draft
+// resumable upload protocol (interop version 6) are served; the latter is
+// selected by the Upload-Draft-Interop-Version request header. Both
+// routes are mounted with the same middlewares, chained ones included.
+//
+// Example:
+//
+//	store, _ := mizutus.NewLocalStore("/var/lib/uploads")
+//	mizutus.Register(server.Group("/api"), "/files", store,
+//		mizutus.WithMaxSize(1<<30),
+//		mizutus.WithCompleteHook(func(ctx context.Context, upload mizutus.Upload) error {
+//			return jobs.Enqueue(ctx, upload.Id, upload.Checksum)
+//		}),
+//	)
+func Register(srv *mizu.Server, pattern string, store Store, opts ...Option) {
+	config := config{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	h := &handler{config: config, store: store, base: srv.Pattern(pattern)}
+
+	srv.Mount(h, pattern, path.Join(pattern, "{id}"))
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") == "" {
+		h.serveCollection(w, r)
+		return
+	}
+	h.serveUpload(w, r)
+}
+
+func (h *handler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	ietf := r.Header.Get("Upload-Draft-Interop-Version") != ""
+	if !ietf && !h.acceptTus(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		h.serveOptions(w)
+	case http.MethodPost:
+		h.serveCreate(w, r, ietf)
+	default:
+		w.Header().Set("Allow", "OPTIONS, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) serveUpload(w http.ResponseWriter, r *http.Request) {
+	ietf := r.Header.Get("Upload-Draft-Interop-Version") != ""
+	if !ietf && !h.acceptTus(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		h.serveOptions(w)
+	case http.MethodHead:
+		h.serveHead(w, r, ietf)
+	case http.MethodPatch:
+		h.serveAppend(w, r, ietf)
+	case http.MethodDelete:
+		if err := h.store.Delete(r.Context(), r.PathValue("id")); err != nil {
+			h.fail(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "OPTIONS, HEAD, PATCH, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// acceptTus sets the Tus-Resumable response header and rejects requests
+// for an unsupported protocol version.
+func (h *handler) acceptTus(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", _TUS_VERSION)
+	if r.Method == http.MethodOptions || r.Header.Get("Tus-Resumable") == _TUS_VERSION {
+		return true
+	}
+	w.Header().Set("Tus-Version", _TUS_VERSION)
+	http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+	return false
+}
+
+func (h *handler) serveOptions(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", _TUS_VERSION)
+	w.Header().Set("Tus-Extension", _TUS_EXTENSIONS)
+	if h.maxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.maxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) serveCreate(w http.ResponseWriter, r *http.Request, ietf bool) {
+	info := Info{Length: -1}
+	complete := false
+	switch {
+	case ietf:
+		var ok bool
+		if complete, ok = parseUploadComplete(r.Header); !ok {
+			http.Error(w, "invalid Upload-Complete header", http.StatusBadRequest)
+			return
+		}
+		if raw := r.Header.Get("Upload-Length"); raw != "" {
+			length, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || length < 0 {
+				http.Error(w, "invalid Upload-Length header", http.StatusBadRequest)
+				return
+			}
+			info.Length = length
+		}
+	case r.Header.Get("Upload-Defer-Length") == "1":
+	default:
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			http.Error(w, "invalid Upload-Length header", http.StatusBadRequest)
+			return
+		}
+		info.Length = length
+	}
+	if h.maxSize > 0 && info.Length > h.maxSize {
+		http.Error(w, "upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info.Metadata = metadata
+
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	info.Id = hex.EncodeToString(id[:])
+	if err := h.store.Create(r.Context(), info); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.Header().Set("Location", h.base+"/"+info.Id)
+
+	if ietf {
+		// Tell the client where to resume before the body is transferred.
+		w.Header().Set("Upload-Draft-Interop-Version", _IETF_INTEROP_VERSION)
+		w.WriteHeader(_STATUS_UPLOAD_RESUMPTION_SUPPORTED)
+	}
+	hasBody := r.ContentLength != 0 && (ietf || r.Header.Get("Content-Type") == _CONTENT_TYPE_TUS)
+	if hasBody || complete || info.Length == 0 {
+		if info, err = h.append(r, info, complete); err != nil {
+			h.fail(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	if ietf {
+		w.Header().Set("Upload-Complete", formatUploadComplete(info))
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *handler) serveHead(w http.ResponseWriter, r *http.Request, ietf bool) {
+	info, err := h.store.Info(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	if info.Length >= 0 {
+		w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	}
+	if ietf {
+		w.Header().Set("Upload-Complete", formatUploadComplete(info))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if info.Length < 0 {
+		w.Header().Set("Upload-Defer-Length", "1")
+	}
+	if len(info.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) serveAppend(w http.ResponseWriter, r *http.Request, ietf bool) {
+	contentType := r.Header.Get("Content-Type")
+	complete := false
+	if ietf {
+		var ok bool
+		if complete, ok = parseUploadComplete(r.Header); !ok {
+			http.Error(w, "invalid Upload-Complete header", http.StatusBadRequest)
+			return
+		}
+		if contentType != "" && contentType != _CONTENT_TYPE_IETF {
+			http.Error(w, "expected "+_CONTENT_TYPE_IETF, http.StatusUnsupportedMediaType)
+			return
+		}
+	} else if contentType != _CONTENT_TYPE_TUS {
+		http.Error(w, "expected "+_CONTENT_TYPE_TUS, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.store.Info(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	if ietf && info.Length >= 0 && info.Offset == info.Length {
+		writeProblem(w, http.StatusBadRequest, "completed-upload", "upload is already completed", nil)
+		return
+	}
+	if offset != info.Offset {
+		if ietf {
+			writeProblem(w, http.StatusConflict, "mismatching-upload-offset", "upload offset mismatch", map[string]any{
+				"expected-offset": info.Offset, "provided-offset": offset,
+			})
+			return
+		}
+		http.Error(w, fmt.Sprintf("upload offset is %d, got %d", info.Offset, offset), http.StatusConflict)
+		return
+	}
+	if raw := r.Header.Get("Upload-Length"); !ietf && raw != "" && info.Length < 0 {
+		length, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || length < info.Offset {
+			http.Error(w, "invalid Upload-Length header", http.StatusBadRequest)
+			return
+		}
+		if h.maxSize > 0 && length > h.maxSize {
+			http.Error(w, "upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := h.store.SetLength(r.Context(), info.Id, length); err != nil {
+			h.fail(w, err)
+			return
+		}
+		info.Length = length
+	}
+
+	if info, err = h.append(r, info, complete); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	if ietf {
+		w.Header().Set("Upload-Complete", formatUploadComplete(info))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// append persists the request body at info.Offset and runs the completion
+// hook when the request finishes the upload. final marks an IETF request
+// carrying Upload-Complete: ?1, which fixes a deferred length.
+func (h *handler) append(r *http.Request, info Info, final bool) (Info, error) {
+	ctx := r.Context()
+	remaining := int64(math.MaxInt64)
+	switch {
+	case info.Length >= 0:
+		remaining = info.Length - info.Offset
+	case h.maxSize > 0:
+		remaining = h.maxSize - info.Offset
+	}
+	if r.ContentLength > remaining {
+		return info, fmt.Errorf("%w: %d > %d", mizu.ErrFileTooLarge, info.Offset+r.ContentLength, info.Offset+remaining)
+	}
+
+	if remaining > 0 {
+		file := mizu.NewFileReader(r.Body, mizu.WithFileLimitBytes(remaining))
+		n, err := h.store.Write(ctx, info.Id, info.Offset, &chunk{file: file, remaining: remaining})
+		info.Offset += n
+		if err != nil {
+			return info, err
+		}
+	} else if n, _ := r.Body.Read(make([]byte, 1)); n > 0 {
+		return info, fmt.Errorf("%w: upload is already %d bytes", mizu.ErrFileTooLarge, info.Offset)
+	}
+
+	if final {
+		if info.Length >= 0 && info.Length != info.Offset {
+			return info, fmt.Errorf("%w: upload completed at %d, declared %d", errInconsistentLength, info.Offset, info.Length)
+		}
+		if info.Length < 0 {
+			if err := h.store.SetLength(ctx, info.Id, info.Offset); err != nil {
+				return info, err
+			}
+			info.Length = info.Offset
+		}
+	}
+	if info.Length < 0 || info.Offset != info.Length {
+		return info, nil
+	}
+	if h.complete == nil {
+		return info, nil
+	}
+
+	// Re-read the stored bytes so the checksum covers every request that
+	// contributed to the upload.
+	rc, err := h.store.Open(ctx, info.Id)
+	if err != nil {
+		return info, fmt.Errorf("%w: %w", errCompleteHook, err)
+	}
+	file := mizu.NewFileReader(rc, mizu.WithFileLimitBytes(info.Length))
+	defer file.Close() // nolint: errcheck
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		return info, fmt.Errorf("%w: %w", errCompleteHook, err)
+	}
+	upload := Upload{Info: info, Checksum: file.Checksum(), ContentType: file.ContentType()}
+	if err := h.complete(ctx, upload); err != nil {
+		return info, fmt.Errorf("%w: %w", errCompleteHook, err)
+	}
+	return info, nil
+}
+
+var (
+	errInconsistentLength = errors.New("inconsistent upload length")
+	errCompleteHook       = errors.New("complete upload")
+)
+
+func (h *handler) fail(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrOffsetMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrUploadLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+	case errors.Is(err, mizu.ErrFileTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errInconsistentLength):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// chunk caps the bytes handed to the store at the remaining upload
+// length, so bytes read past the FileReader limit are never persisted.
+type chunk struct {
+	file      *mizu.FileReader
+	remaining int64
+}
+
+func (c *chunk) Read(p []byte) (int, error) {
+	n, err := c.file.Read(p)
+	if int64(n) > c.remaining {
+		n = int(c.remaining)
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+func parseUploadComplete(header http.Header) (bool, bool) {
+	switch header.Get("Upload-Complete") {
+	case "?1":
+		return true, true
+	case "?0":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func formatUploadComplete(info Info) string {
+	if info.Length >= 0 && info.Offset == info.Length {
+		return "?1"
+	}
+	return "?0"
+}
+
+func parseMetadata(raw string) (map[string]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for pair := range strings.SplitSeq(raw, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" || strings.ContainsAny(key, " ,") {
+			return nil, fmt.Errorf("invalid Upload-Metadata key %q", key)
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, fmt.Errorf("duplicate Upload-Metadata key %q", key)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q: %w", key, err)
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		if value == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func writeProblem(w http.ResponseWriter, status int, kind, title string, extra map[string]any) {
+	problem := map[string]any{"type": _IETF_PROBLEM_TYPE + kind, "title": title}
+	maps.Copy(problem, extra)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,277 @@
+package mizutus_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/humbornjo/mizu"
+	"github.com/humbornjo/mizu/mizutus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type completedUploads struct {
+	mu      sync.Mutex
+	uploads []mizutus.Upload
+}
+
+func (c *completedUploads) hook(ctx context.Context, upload mizutus.Upload) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploads = append(c.uploads, upload)
+	return nil
+}
+
+func newTusServer(t *testing.T, opts ...mizutus.Option) (*httptest.Server, *completedUploads) {
+	t.Helper()
+
+	store, err := mizutus.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	completed := &completedUploads{}
+	srv := mizu.NewServer("tus")
+	opts = append(opts, mizutus.WithCompleteHook(completed.hook))
+	mizutus.Register(srv.Group("/api"), "/files", store, opts...)
+
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	return server, completed
+}
+
+func doTus(t *testing.T, method, url string, body []byte, header map[string]string) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	request.Header.Set("Tus-Resumable", "1.0.0")
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, response.Body)
+	require.NoError(t, response.Body.Close())
+	return response
+}
+
+func TestMizuTus_Discovery(t *testing.T) {
+	server, _ := newTusServer(t, mizutus.WithMaxSize(1024))
+
+	response := doTus(t, http.MethodOptions, server.URL+"/api/files", nil, nil)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "1.0.0", response.Header.Get("Tus-Resumable"))
+	assert.Equal(t, "1.0.0", response.Header.Get("Tus-Version"))
+	assert.Contains(t, response.Header.Get("Tus-Extension"), "creation")
+	assert.Equal(t, "1024", response.Header.Get("Tus-Max-Size"))
+
+	response = doTus(t, http.MethodPost, server.URL+"/api/files", nil, map[string]string{
+		"Tus-Resumable": "0.2.2", "Upload-Length": "1",
+	})
+	assert.Equal(t, http.StatusPreconditionFailed, response.StatusCode)
+	assert.Equal(t, "1.0.0", response.Header.Get("Tus-Version"))
+}
+
+func TestMizuTus_Upload(t *testing.T) {
+	server, completed := newTusServer(t)
+	data := []byte("hello, resumable mizu")
+	patch := map[string]string{"Content-Type": "application/offset+octet-stream"}
+
+	response := doTus(t, http.MethodPost, server.URL+"/api/files", nil, map[string]string{
+		"Upload-Length":   "21",
+		"Upload-Metadata": "filename aGVsbG8udHh0,private",
+	})
+	require.Equal(t, http.StatusCreated, response.StatusCode)
+	location := response.Header.Get("Location")
+	require.True(t, strings.HasPrefix(location, "/api/files/"), location)
+	url := server.URL + location
+
+	patch["Upload-Offset"] = "0"
+	response = doTus(t, http.MethodPatch, url, data[:5], patch)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "5", response.Header.Get("Upload-Offset"))
+
+	response = doTus(t, http.MethodHead, url, nil, nil)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "5", response.Header.Get("Upload-Offset"))
+	assert.Equal(t, "21", response.Header.Get("Upload-Length"))
+	assert.Equal(t, "no-store", response.Header.Get("Cache-Control"))
+	assert.Equal(t, "filename aGVsbG8udHh0,private", response.Header.Get("Upload-Metadata"))
+
+	patch["Upload-Offset"] = "3"
+	response = doTus(t, http.MethodPatch, url, data[3:], patch)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	patch["Upload-Offset"] = "5"
+	response = doTus(t, http.MethodPatch, url, append(data[5:], "!"...), patch)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+	assert.Empty(t, completed.uploads)
+
+	response = doTus(t, http.MethodPatch, url, data[5:], patch)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "21", response.Header.Get("Upload-Offset"))
+
+	require.Len(t, completed.uploads, 1)
+	checksum := sha256.Sum256(data)
+	upload := completed.uploads[0]
+	assert.Equal(t, hex.EncodeToString(checksum[:]), upload.Checksum)
+	assert.Equal(t, "text/plain; charset=utf-8", upload.ContentType)
+	assert.Equal(t, int64(21), upload.Length)
+	assert.Equal(t, map[string]string{"filename": "hello.txt", "private": ""}, upload.Metadata)
+
+	response = doTus(t, http.MethodDelete, url, nil, nil)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	response = doTus(t, http.MethodHead, url, nil, nil)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestMizuTus_CreationWithUpload(t *testing.T) {
+	server, completed := newTusServer(t, mizutus.WithMaxSize(8))
+
+	response := doTus(t, http.MethodPost, server.URL+"/api/files", []byte("tiny"), map[string]string{
+		"Upload-Length": "4", "Content-Type": "application/offset+octet-stream",
+	})
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "4", response.Header.Get("Upload-Offset"))
+	require.Len(t, completed.uploads, 1)
+
+	response = doTus(t, http.MethodPost, server.URL+"/api/files", nil, map[string]string{"Upload-Length": "9"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+}
+
+func TestMizuTus_DeferLength(t *testing.T) {
+	server, completed := newTusServer(t)
+
+	response := doTus(t, http.MethodPost, server.URL+"/api/files", nil, map[string]string{"Upload-Defer-Length": "1"})
+	require.Equal(t, http.StatusCreated, response.StatusCode)
+	url := server.URL + response.Header.Get("Location")
+
+	response = doTus(t, http.MethodHead, url, nil, nil)
+	assert.Equal(t, "1", response.Header.Get("Upload-Defer-Length"))
+
+	response = doTus(t, http.MethodPatch, url, []byte("abc"), map[string]string{
+		"Content-Type": "application/offset+octet-stream", "Upload-Offset": "0", "Upload-Length": "3",
+	})
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	require.Len(t, completed.uploads, 1)
+	assert.Equal(t, int64(3), completed.uploads[0].Length)
+}
+
+func TestMizuTus_IetfDraft(t *testing.T) {
+	server, completed := newTusServer(t)
+	header := map[string]string{"Upload-Draft-Interop-Version": "6", "Tus-Resumable": ""}
+
+	header["Upload-Complete"] = "?0"
+	response := doTus(t, http.MethodPost, server.URL+"/api/files", []byte("partial "), header)
+	require.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "8", response.Header.Get("Upload-Offset"))
+	assert.Equal(t, "?0", response.Header.Get("Upload-Complete"))
+	url := server.URL + response.Header.Get("Location")
+
+	response = doTus(t, http.MethodHead, url, nil, header)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "8", response.Header.Get("Upload-Offset"))
+	assert.Empty(t, response.Header.Get("Upload-Length"))
+
+	header["Upload-Complete"] = "?1"
+	header["Upload-Offset"] = "2"
+	header["Content-Type"] = "application/partial-upload"
+	response = doTus(t, http.MethodPatch, url, []byte("upload"), header)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.Equal(t, "application/problem+json", response.Header.Get("Content-Type"))
+
+	header["Upload-Offset"] = "8"
+	response = doTus(t, http.MethodPatch, url, []byte("upload"), header)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "?1", response.Header.Get("Upload-Complete"))
+
+	require.Len(t, completed.uploads, 1)
+	checksum := sha256.Sum256([]byte("partial upload"))
+	assert.Equal(t, hex.EncodeToString(checksum[:]), completed.uploads[0].Checksum)
+	assert.Equal(t, int64(14), completed.uploads[0].Length)
+
+	header["Upload-Offset"] = "14"
+	response = doTus(t, http.MethodPatch, url, []byte("more"), header)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestMizuTus_Middleware(t *testing.T) {
+	store, err := mizutus.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	srv := mizu.NewServer("tus")
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	mizutus.Register(srv.Group("/api").Use(auth), "/files", store)
+	require.NoError(t, store.Create(context.Background(), mizutus.Info{Id: "upload", Length: 4}))
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+
+	for _, method := range []string{http.MethodPost, http.MethodHead, http.MethodPatch, http.MethodDelete} {
+		url := server.URL + "/api/files"
+		if method != http.MethodPost {
+			url += "/upload"
+		}
+		response := doTus(t, method, url, nil, map[string]string{"Upload-Length": "4"})
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode, method)
+	}
+
+	response := doTus(t, http.MethodHead, server.URL+"/api/files/upload", nil, map[string]string{
+		"Authorization": "Bearer token",
+	})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestMizuTus_LocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := mizutus.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Create(ctx, mizutus.Info{Id: "upload", Length: 6}))
+	n, err := store.Write(ctx, "upload", 0, strings.NewReader("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	_, err = store.Write(ctx, "upload", 1, strings.NewReader("def"))
+	assert.ErrorIs(t, err, mizutus.ErrOffsetMismatch)
+
+	info, err := store.Info(ctx, "upload")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Offset)
+
+	_, err = store.Info(ctx, "../escape")
+	assert.ErrorIs(t, err, mizutus.ErrUploadNotFound)
+
+	require.NoError(t, store.Create(ctx, mizutus.Info{Id: "deferred", Length: -1}))
+	reader, writer := io.Pipe()
+	written := make(chan error)
+	go func() {
+		_, err := store.Write(ctx, "deferred", 0, reader)
+		written <- err
+	}()
+	_, err = writer.Write([]byte("ab"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.SetLength(ctx, "deferred", 2), mizutus.ErrUploadLocked)
+	assert.ErrorIs(t, store.Delete(ctx, "deferred"), mizutus.ErrUploadLocked)
+	require.NoError(t, writer.Close())
+	require.NoError(t, <-written)
+	require.NoError(t, store.SetLength(ctx, "deferred", 2))
+	info, err = store.Info(ctx, "deferred")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Length)
+
+	require.NoError(t, store.Delete(ctx, "upload"))
+	assert.ErrorIs(t, store.Delete(ctx, "upload"), mizutus.ErrUploadNotFound)
+}
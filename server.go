@@ -48,11 +48,12 @@ type Server struct {
 	initialized    *atomic.Bool
 	isShuttingDown *atomic.Bool
 
-	ctx         context.Context
-	name        string
-	config      *serverConfig
-	hookStartup *[]func(*Server)
-	hookHandler *[]func(*Server)
+	ctx          context.Context
+	name         string
+	config       *serverConfig
+	hookStartup  *[]func(*Server)
+	hookHandler  *[]func(*Server)
+	hookShutdown *[]func(*Server)
 
 	prefix   []string
 	buckets  []*bucket
@@ -67,8 +68,9 @@ func (s *Server) Name() string {
 type hookOption func(*hookConfig)
 
 type hookConfig struct {
-	hookStartup func(*Server)
-	hookHandler func(*Server)
+	hookStartup  func(*Server)
+	hookHandler  func(*Server)
+	hookShutdown func(*Server)
 }
 
 // WithHookStartup registers a hook function when Calling ServeContext.
@@ -85,6 +87,14 @@ func WithHookHandler(hook func(*Server)) hookOption {
 	}
 }
 
+// WithHookShutdown registers a hook function when ServeContext starts
+// shutting down, before the readiness drain delay.
+func WithHookShutdown(hook func(*Server)) hookOption {
+	return func(config *hookConfig) {
+		config.hookShutdown = hook
+	}
+}
+
 // Hook registers a hook function for the given key. If key is already
 // bounded with a none nil value, it is used. Otherwise, if the value
 // is nil, a new value will be initiated, bounding to the key. The
@@ -121,6 +131,9 @@ func Hook[K any, V any](s *Server, key K, val *V, opts ...hookOption) *V {
 	if config.hookStartup != nil {
 		*s.hookStartup = append(*s.hookStartup, config.hookStartup)
 	}
+	if config.hookShutdown != nil {
+		*s.hookShutdown = append(*s.hookShutdown, config.hookShutdown)
+	}
 
 	return ret
 }
@@ -208,6 +221,9 @@ func (s *Server) ServeContext(ctx context.Context, addr string) error {
 
 		s.isShuttingDown.Store(true)
 		fmt.Println("✅ [INFO] Server shutting down...")
+		for _, hook := range *s.hookShutdown {
+			hook(s)
+		}
 
 		if ReadinessDrainDelayPeriod > 0 {
 			// Give time for readiness check to propagate
@@ -278,6 +294,35 @@ func (s *Server) Handle(pattern string, handler http.Handler) {
 	})
 }
 
+// Mount registers handler on every pattern, e.g. a collection and its
+// items. Middlewares are drained once and wrap all the routes alike,
+// whereas chained middlewares would only apply to the first route when
+// registered one by one.
+func (s *Server) Mount(handler http.Handler, patterns ...string) {
+	registeredPaths := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		registeredPath := path.Join(append(s.prefix, pattern)...)
+		if pattern != string(os.PathSeparator) &&
+			strings.TrimSuffix(pattern, string(os.PathSeparator)) != pattern {
+			registeredPath += string(os.PathSeparator)
+		}
+		registeredPaths = append(registeredPaths, registeredPath)
+	}
+
+	var registeredFunc = handler
+	Immediate(s, _CTXKEY, func(v *routes) {
+		for mw := range s.drain() {
+			registeredFunc = mw(registeredFunc)
+		}
+		for _, registeredPath := range registeredPaths {
+			if v != nil {
+				v.add("", registeredPath, s.prefix...)
+			}
+			s.inner.Handle(registeredPath, registeredFunc)
+		}
+	})
+}
+
 func (s *Server) Get(pattern string, handler http.HandlerFunc) {
 	registeredPath := path.Join(append(s.prefix, pattern)...)
 	if pattern != string(os.PathSeparator) &&
@@ -1,14 +1,18 @@
 package mizu_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/humbornjo/mizu"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServer_HTTPMethods(t *testing.T) {
@@ -193,6 +197,31 @@ func TestServer_Handle_And_HandleFunc(t *testing.T) {
 	}
 }
 
+func TestServer_Mount(t *testing.T) {
+	srv := mizu.NewServer("test-server")
+	srv.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Auth", "middleware-applied")
+			next.ServeHTTP(w, r)
+		})
+	}).Mount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("item " + r.PathValue("id")))
+	}), "/items", "/items/{id}")
+	srv.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) {})
+
+	for path, body := range map[string]string{"/items": "item ", "/items/7": "item 7"} {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, body, rr.Body.String())
+		assert.Equal(t, "middleware-applied", rr.Header().Get("X-Auth"), path)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/public", nil))
+	assert.Empty(t, rr.Header().Get("X-Auth"), "chained middlewares are drained once")
+}
+
 func TestServer_Middleware_Mux(t *testing.T) {
 	t.Run("middleware_actually_applied", func(t *testing.T) {
 		srv := mizu.NewServer("test-server")
@@ -466,6 +495,35 @@ func TestServer_Hook_WithHookHandler(t *testing.T) {
 	assert.True(t, handlerCalled, "WithHookHandler should have been called")
 }
 
+func TestServer_Hook_WithHookShutdown(t *testing.T) {
+	srv := mizu.NewServer("test-server", mizu.WithReadinessDrainDelay(50*time.Millisecond))
+	type testKey string
+
+	var drainingAtHook atomic.Bool
+	shutdown := make(chan struct{})
+	_ = mizu.Hook(srv, testKey("shutdown-key"), &struct{}{}, mizu.WithHookShutdown(func(s *mizu.Server) {
+		// Readiness is already failing when the hook runs
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		drainingAtHook.Store(rec.Code != http.StatusOK)
+		close(shutdown)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeContext(ctx, "127.0.0.1:0") }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-shutdown:
+	case <-time.After(time.Second):
+		t.Fatal("WithHookShutdown should have been called")
+	}
+	assert.True(t, drainingAtHook.Load())
+	require.NoError(t, <-done)
+}
+
 func TestServer_Hook_MultipleHooks(t *testing.T) {
 	srv := mizu.NewServer("test-server")
 	handler1Called := false